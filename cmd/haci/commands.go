@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Nexinto/go-haci-client/haci"
+)
+
+var commands = map[string]command{
+	"get": {
+		args: "<network>...",
+		help: "Show networks.",
+		flags: func(fs *flag.FlagSet) func(ctx context.Context, c haci.Client, args []string, out *output) error {
+			return func(ctx context.Context, c haci.Client, args []string, out *output) error {
+				if len(args) == 0 {
+					return errUsage
+				}
+				networks := []haci.Network{}
+				for _, network := range args {
+					n, err := c.Get(ctx, network)
+					if err != nil {
+						return err
+					}
+					networks = append(networks, n)
+				}
+				return out.print(networks)
+			}
+		},
+	},
+	"list": {
+		args: "<supernet>",
+		help: "List the subnets of a supernet.",
+		flags: func(fs *flag.FlagSet) func(ctx context.Context, c haci.Client, args []string, out *output) error {
+			return func(ctx context.Context, c haci.Client, args []string, out *output) error {
+				if len(args) != 1 {
+					return errUsage
+				}
+				networks, err := c.List(ctx, args[0])
+				if err != nil {
+					return err
+				}
+				return out.print(networks)
+			}
+		},
+	},
+	"assign": {
+		args: "[-d description] [-t tags] <supernet> <prefix length>",
+		help: "Assign the next free subnet of a supernet.",
+		flags: func(fs *flag.FlagSet) func(ctx context.Context, c haci.Client, args []string, out *output) error {
+			description := fs.String("d", "", "description")
+			tags := fs.String("t", "", "comma-separated tags")
+			return func(ctx context.Context, c haci.Client, args []string, out *output) error {
+				if len(args) != 2 {
+					return errUsage
+				}
+				cidr, err := strconv.Atoi(strings.TrimPrefix(args[1], "/"))
+				if err != nil {
+					return fmt.Errorf("invalid prefix length %q", args[1])
+				}
+				n, err := c.Assign(ctx, args[0], *description, cidr, splitList(*tags))
+				if err != nil {
+					return err
+				}
+				return out.print([]haci.Network{n})
+			}
+		},
+	},
+	"add": {
+		args: "[-d description] [-t tags] <network>",
+		help: "Add a network.",
+		flags: func(fs *flag.FlagSet) func(ctx context.Context, c haci.Client, args []string, out *output) error {
+			description := fs.String("d", "", "description")
+			tags := fs.String("t", "", "comma-separated tags")
+			return func(ctx context.Context, c haci.Client, args []string, out *output) error {
+				if len(args) != 1 {
+					return errUsage
+				}
+				return c.Add(ctx, args[0], *description, splitList(*tags))
+			}
+		},
+	},
+	"delete": {
+		args: "<network>...",
+		help: "Delete networks.",
+		flags: func(fs *flag.FlagSet) func(ctx context.Context, c haci.Client, args []string, out *output) error {
+			return func(ctx context.Context, c haci.Client, args []string, out *output) error {
+				if len(args) == 0 {
+					return errUsage
+				}
+				for _, network := range args {
+					if err := c.Delete(ctx, network); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+		},
+	},
+	"import": {
+		args: "[-concurrency n] [-dry-run] <file.csv>",
+		help: "Add the networks of a CSV file with network,description,tags rows.",
+		flags: func(fs *flag.FlagSet) func(ctx context.Context, c haci.Client, args []string, out *output) error {
+			concurrency := fs.Int("concurrency", 0, "number of parallel requests, 0 for the client default")
+			dryRun := fs.Bool("dry-run", false, "only validate the file and show what would be added")
+			return func(ctx context.Context, c haci.Client, args []string, out *output) error {
+				if len(args) != 1 {
+					return errUsage
+				}
+				return importNetworks(ctx, c, args[0], *concurrency, *dryRun, out.in, out.w)
+			}
+		},
+	},
+	"plan": {
+		args: "<manifest.yaml>",
+		help: "Show the changes that apply would make.",
+		flags: func(fs *flag.FlagSet) func(ctx context.Context, c haci.Client, args []string, out *output) error {
+			return func(ctx context.Context, c haci.Client, args []string, out *output) error {
+				if len(args) != 1 {
+					return errUsage
+				}
+				_, err := planManifest(ctx, c, args[0], out.w)
+				return err
+			}
+		},
+	},
+	"apply": {
+		args: "[-yes] <manifest.yaml>",
+		help: "Create, update and delete networks to match a manifest.",
+		flags: func(fs *flag.FlagSet) func(ctx context.Context, c haci.Client, args []string, out *output) error {
+			yes := fs.Bool("yes", false, "apply without asking for confirmation")
+			return func(ctx context.Context, c haci.Client, args []string, out *output) error {
+				if len(args) != 1 {
+					return errUsage
+				}
+				plan, err := planManifest(ctx, c, args[0], out.w)
+				if err != nil || plan.Empty() {
+					return err
+				}
+				if !*yes && !confirm(out.in, out.w, "Apply these changes?") {
+					return errors.New("apply cancelled")
+				}
+				if err := haci.ApplyPlan(ctx, c, plan); err != nil {
+					return err
+				}
+				fmt.Fprintf(out.w, "%d changes applied.\n", len(plan.Changes))
+				return nil
+			}
+		},
+	},
+	"export": {
+		args: "[-format json|csv] <root>",
+		help: "Write all networks of a root with their tags and metadata.",
+		flags: func(fs *flag.FlagSet) func(ctx context.Context, c haci.Client, args []string, out *output) error {
+			format := fs.String("format", "json", "json or csv")
+			return func(ctx context.Context, c haci.Client, args []string, out *output) error {
+				if len(args) != 1 {
+					return errUsage
+				}
+				return c.Export(ctx, args[0], out.w, haci.ExportFormat(*format))
+			}
+		},
+	},
+	"restore": {
+		args: "[-root name] [-format json|csv] [-mode fail|skip|overwrite] <file>",
+		help: "Recreate the networks of an export.",
+		flags: func(fs *flag.FlagSet) func(ctx context.Context, c haci.Client, args []string, out *output) error {
+			root := fs.String("root", "", "root to restore into, default the root of the export")
+			format := fs.String("format", "json", "json or csv")
+			mode := fs.String("mode", "fail", "what to do with networks that exist with other descriptions or tags: fail, skip or overwrite")
+			return func(ctx context.Context, c haci.Client, args []string, out *output) error {
+				if len(args) != 1 {
+					return errUsage
+				}
+				modes := map[string]haci.ImportMode{
+					"fail":      haci.ImportFailOnConflict,
+					"skip":      haci.ImportSkipExisting,
+					"overwrite": haci.ImportOverwrite,
+				}
+				m, ok := modes[*mode]
+				if !ok {
+					return fmt.Errorf("invalid mode %q", *mode)
+				}
+				f, err := os.Open(args[0])
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+
+				result, err := c.Import(ctx, f, haci.ImportOptions{Root: *root, Format: haci.ExportFormat(*format), Mode: m})
+				if err == nil || result != (haci.ImportResult{}) {
+					fmt.Fprintf(out.w, "%d added, %d updated, %d skipped\n", result.Added, result.Updated, result.Skipped)
+				}
+				return err
+			}
+		},
+	},
+	"search": {
+		args: "[-exact] [-t tags [-all]] [text]",
+		help: "Search networks by description or tags.",
+		flags: func(fs *flag.FlagSet) func(ctx context.Context, c haci.Client, args []string, out *output) error {
+			exact := fs.Bool("exact", false, "match the whole description")
+			tags := fs.String("t", "", "comma-separated tags the networks must carry")
+			all := fs.Bool("all", false, "match networks carrying all tags instead of any")
+			return func(ctx context.Context, c haci.Client, args []string, out *output) error {
+				if len(args) > 1 || len(args) == 0 && *tags == "" {
+					return errUsage
+				}
+				text := ""
+				if len(args) == 1 {
+					text = args[0]
+				}
+
+				var networks []haci.Network
+				var err error
+				if *tags != "" {
+					var tagged []haci.Network
+					tagged, err = c.SearchTags(ctx, splitList(*tags), *all)
+					for _, n := range tagged {
+						if *exact && n.Description == text || !*exact && strings.Contains(n.Description, text) {
+							networks = append(networks, n)
+						}
+					}
+				} else {
+					networks, err = c.Search(ctx, text, *exact)
+				}
+				if err != nil {
+					return err
+				}
+				return out.print(networks)
+			}
+		},
+	},
+}
+
+// planManifest loads the manifest at path and prints the plan for it.
+func planManifest(ctx context.Context, c haci.Client, path string, out io.Writer) (*haci.Plan, error) {
+	m, err := haci.LoadManifest(path)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := haci.PlanManifest(ctx, c, m)
+	if err != nil {
+		return nil, err
+	}
+	if plan.Empty() {
+		fmt.Fprintln(out, "No changes.")
+	} else {
+		fmt.Fprintln(out, plan.String())
+	}
+	return plan, nil
+}
+
+// confirm asks question on out and reports whether it was answered yes on
+// in.
+func confirm(in *bufio.Reader, out io.Writer, question string) bool {
+	fmt.Fprintf(out, "%s [y/N] ", question)
+	answer, _ := in.ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
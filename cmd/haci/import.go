@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/Nexinto/go-haci-client/haci"
+)
+
+// importRow is a network read from an import file.
+type importRow struct {
+	line int
+	spec haci.NetworkSpec
+}
+
+// importNetworks adds the networks of the CSV file path, "-" for stdin,
+// with BulkAdd. Each row holds a network, a description and comma-separated
+// tags; the description and tags may be left out. A header row starting
+// with "network" and lines starting with # are skipped.
+//
+// The file is validated completely before anything is added. Networks that
+// already exist with the same description and tags are skipped, so an
+// import that failed half-way can be run again; networks that exist with
+// different ones are reported as conflicts and left unchanged.
+func importNetworks(ctx context.Context, c haci.Client, path string, concurrency int, dryRun bool, stdin io.Reader, out io.Writer) error {
+	rows, err := readImport(path, stdin)
+	if err != nil {
+		return err
+	}
+
+	networks := []string{}
+	for _, r := range rows {
+		networks = append(networks, r.spec.Network)
+	}
+	existing, err := c.GetMany(ctx, networks, concurrency)
+	var bulkErr *haci.BulkError
+	if errors.As(err, &bulkErr) {
+		for network, err := range bulkErr.Failed {
+			if !errors.Is(err, haci.ErrNotFound) {
+				return fmt.Errorf("looking up %s: %w", network, err)
+			}
+		}
+	} else if err != nil {
+		return err
+	}
+
+	specs := []haci.NetworkSpec{}
+	present, conflicts := 0, 0
+	for _, r := range rows {
+		n, ok := existing[r.spec.Network]
+		switch {
+		case !ok:
+			specs = append(specs, r.spec)
+		case n.Description == r.spec.Description && sameTags(n.Tags, r.spec.Tags):
+			present++
+		default:
+			conflicts++
+			fmt.Fprintf(out, "conflict  %s: exists as %q with tags %q (line %d)\n", n.Network, n.Description, n.Tags, r.line)
+		}
+	}
+
+	failed := 0
+	if dryRun {
+		for _, s := range specs {
+			fmt.Fprintf(out, "add       %s\n", s.Network)
+		}
+	} else if err := c.BulkAdd(ctx, specs, concurrency); errors.As(err, &bulkErr) {
+		failed = len(bulkErr.Failed)
+		for _, s := range specs {
+			if err, ok := bulkErr.Failed[s.Network]; ok {
+				fmt.Fprintf(out, "failed    %s: %s\n", s.Network, err.Error())
+			}
+		}
+	} else if err != nil {
+		return err
+	}
+
+	verb := "added"
+	if dryRun {
+		verb = "to add"
+	}
+	fmt.Fprintf(out, "%d networks: %d %s, %d already present, %d conflicting, %d failed\n",
+		len(rows), len(specs)-failed, verb, present, conflicts, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d networks failed, run the import again to retry them", failed)
+	}
+	if conflicts > 0 {
+		return fmt.Errorf("%d networks conflict with existing ones", conflicts)
+	}
+	return nil
+}
+
+// readImport reads and validates the rows of an import file, or of stdin
+// if path is "-".
+func readImport(path string, stdin io.Reader) ([]importRow, error) {
+	f := stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		f = file
+	}
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	r.Comment = '#'
+
+	rows := []importRow{}
+	lines := map[string]int{}
+	problems := []string{}
+	for first := true; ; first = false {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := r.FieldPos(0)
+		if first && strings.EqualFold(record[0], "network") {
+			continue
+		}
+		if len(record) > 3 {
+			problems = append(problems, fmt.Sprintf("line %d: %d fields, want network,description,tags", line, len(record)))
+			continue
+		}
+
+		record = append(record, "", "")
+		spec := haci.NetworkSpec{Network: record[0], Description: record[1]}
+		for _, t := range splitList(record[2]) {
+			spec.Tags = append(spec.Tags, strings.TrimSpace(t))
+		}
+		if err := spec.Validate(); err != nil {
+			problems = append(problems, fmt.Sprintf("line %d: %s", line, err.Error()))
+			continue
+		}
+		if dup, ok := lines[spec.Network]; ok {
+			problems = append(problems, fmt.Sprintf("line %d: %s is already on line %d", line, spec.Network, dup))
+			continue
+		}
+		lines[spec.Network] = line
+		rows = append(rows, importRow{line: line, spec: spec})
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid import file %s:\n  %s", path, strings.Join(problems, "\n  "))
+	}
+	return rows, nil
+}
+
+// sameTags reports whether a and b contain the same tags in any order.
+func sameTags(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a = append([]string{}, a...)
+	b = append([]string{}, b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,163 @@
+// Command haci works with networks in HaCi from the command line.
+//
+// Usage:
+//
+//	haci [flags] <command> [arguments]
+//
+// The commands are get, list, assign, add, delete, search, import, plan,
+// apply, export and restore; run "haci <command> -h" for their arguments. The server is
+// configured with the flags below, a configuration file read by
+// haci.LoadConfig, or the environment variables of
+// haci.NewWebClientFromEnv. Passwords and tokens are only taken from the
+// environment or the configuration, never from flags.
+//
+// Networks are printed in the format selected with -o:
+//
+//	table                  aligned columns with a header (the default)
+//	json                   a JSON array of networks
+//	csv                    comma-separated values with a header
+//	columns=<f>,<f>...     a table of the given fields
+//	jsonpath=<template>    the template for each network, one per line
+//
+// Fields are named like in the JSON form of haci.Network, e.g. network,
+// description, tags, createDate or state. A jsonpath template contains
+// expressions like {.network}, which are replaced by the field of the
+// network; other text is printed as is, e.g.
+//
+//	haci -o 'jsonpath={.network} {.description}' list 10.0.0.0/16
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/Nexinto/go-haci-client/haci"
+)
+
+// errUsage is returned for invalid arguments, after the usage was printed.
+var errUsage = errors.New("usage")
+
+// command is a subcommand of haci.
+type command struct {
+	args  string
+	help  string
+	flags func(fs *flag.FlagSet) func(ctx context.Context, c haci.Client, args []string, out *output) error
+}
+
+func main() {
+	err := run(context.Background(), os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	switch {
+	case errors.Is(err, errUsage):
+		os.Exit(2)
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "haci: %s\n", err.Error())
+		os.Exit(1)
+	}
+}
+
+// run runs the haci command with args, reading confirmations and files
+// named "-" from stdin.
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("haci", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	url := fs.String("url", "", "URL of the HaCi server (default $HACI_URL)")
+	root := fs.String("root", "", "HaCi root (default $HACI_ROOT)")
+	user := fs.String("user", "", "user name for basic auth, the password is read from $HACI_PASSWORD")
+	timeout := fs.Duration("timeout", 0, "timeout of each request (default $HACI_TIMEOUT, the timeout of the profile or "+haci.DefaultTimeout.String()+")")
+	config := fs.String("config", "", "configuration file with profiles")
+	profile := fs.String("profile", "", "profile of the configuration file")
+	format := fs.String("o", "table", "output format: table, json, csv, columns=<fields> or jsonpath=<template>")
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: haci [flags] <command> [arguments]\n\nCommands:\n")
+		names := []string{}
+		for name := range commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(stderr, "  %-8s %s\n", name, commands[name].help)
+		}
+		fmt.Fprintf(stderr, "\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	out, err := newOutput(stdout, *format)
+	if err != nil {
+		return err
+	}
+	out.in = bufio.NewReader(stdin)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errUsage
+	}
+
+	name := fs.Arg(0)
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(stderr, "haci: unknown command %q\n", name)
+		fs.Usage()
+		return errUsage
+	}
+	cmdFlags := flag.NewFlagSet("haci "+name, flag.ContinueOnError)
+	cmdFlags.SetOutput(stderr)
+	cmdFlags.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: haci %s %s\n\n%s\n", name, cmd.args, cmd.help)
+		cmdFlags.PrintDefaults()
+	}
+	runCmd := cmd.flags(cmdFlags)
+	if err := cmdFlags.Parse(fs.Args()[1:]); err != nil {
+		return errUsage
+	}
+
+	opts := []haci.Option{}
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "timeout" {
+			opts = append(opts, haci.WithTimeout(*timeout))
+		}
+	})
+	if *root != "" {
+		opts = append(opts, haci.WithRoot(*root))
+	}
+	if *user != "" {
+		opts = append(opts, haci.WithBasicAuth(*user, os.Getenv("HACI_PASSWORD")))
+	}
+
+	var c *haci.WebClient
+	switch {
+	case *config != "":
+		var cfg *haci.Config
+		if cfg, err = haci.LoadConfig(*config); err == nil {
+			c, err = cfg.Client(*profile, opts...)
+		}
+	case *url != "":
+		c, err = haci.NewWebClient(*url, opts...)
+	default:
+		c, err = haci.NewWebClientFromEnv(opts...)
+	}
+	if err != nil {
+		return err
+	}
+
+	err = runCmd(ctx, c, cmdFlags.Args(), out)
+	if errors.Is(err, errUsage) {
+		cmdFlags.Usage()
+	}
+	return err
+}
+
+// splitList splits a comma-separated flag value.
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
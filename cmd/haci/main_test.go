@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Nexinto/go-haci-client/haci"
+	"github.com/Nexinto/go-haci-client/haci/hacitest"
+)
+
+func TestApplyConfirm(t *testing.T) {
+	ctx := context.Background()
+	s := hacitest.NewServer("test")
+	defer s.Close()
+	manifest := filepath.Join(t.TempDir(), "manifest.yaml")
+	err := os.WriteFile(manifest, []byte("supernets:\n- network: 10.0.0.0/16\n  networks:\n  - network: 10.0.1.0/24\n    description: web\n"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	err = run(ctx, []string{"-url", s.URL, "-root", "test", "apply", manifest}, strings.NewReader("n\n"), &stdout, &stderr)
+	if err == nil || !strings.Contains(err.Error(), "cancelled") {
+		t.Errorf("got %v, want apply cancelled", err)
+	}
+	if _, err := s.Fake("test").Get(ctx, "10.0.1.0/24"); !errors.Is(err, haci.ErrNotFound) {
+		t.Errorf("cancelled apply added 10.0.1.0/24: %v", err)
+	}
+
+	stdout.Reset()
+	if err := run(ctx, []string{"-url", s.URL, "-root", "test", "apply", manifest}, strings.NewReader("y\n"), &stdout, &stderr); err != nil {
+		t.Fatalf("apply: %s", err)
+	}
+	if n, err := s.Fake("test").Get(ctx, "10.0.1.0/24"); err != nil || n.Description != "web" {
+		t.Errorf("got %v, %v after apply", n, err)
+	}
+}
+
+func TestRestoreFailure(t *testing.T) {
+	ctx := context.Background()
+	s := hacitest.NewServer("test")
+	defer s.Close()
+	fake := s.Fake("test")
+	if err := fake.Add(ctx, "10.0.0.0/24", "web", nil); err != nil {
+		t.Fatal(err)
+	}
+	var export bytes.Buffer
+	if err := fake.Export(ctx, "", &export, haci.ExportJSON); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(t.TempDir(), "export.json")
+	if err := os.WriteFile(file, export.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := fake.Update(ctx, "10.0.0.0/24", "db", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// A conflict fails the restore before anything was changed, so no
+	// summary is printed.
+	var stdout, stderr bytes.Buffer
+	err := run(ctx, []string{"-url", s.URL, "-root", "test", "restore", file}, strings.NewReader(""), &stdout, &stderr)
+	if err == nil {
+		t.Error("restore of a conflicting network succeeded")
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("failed restore printed %q", stdout.String())
+	}
+
+	stdout.Reset()
+	if err := run(ctx, []string{"-url", s.URL, "-root", "test", "restore", "-mode", "overwrite", file}, strings.NewReader(""), &stdout, &stderr); err != nil {
+		t.Fatalf("restore: %s", err)
+	}
+	if got := stdout.String(); got != "0 added, 1 updated, 0 skipped\n" {
+		t.Errorf("restore printed %q", got)
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Nexinto/go-haci-client/haci"
+)
+
+// defaultColumns are the fields shown by the table and csv formats.
+var defaultColumns = []string{"network", "description", "tags"}
+
+// output prints networks in the format selected with -o.
+type output struct {
+	w        io.Writer
+	in       *bufio.Reader // the standard input, for confirmations and "-"
+	format   string
+	columns  []string
+	template []string
+}
+
+// newOutput returns an output to w in the format described by spec.
+func newOutput(w io.Writer, spec string) (*output, error) {
+	o := &output{w: w, format: spec, columns: defaultColumns}
+
+	switch {
+	case spec == "table" || spec == "json" || spec == "csv":
+	case strings.HasPrefix(spec, "columns="):
+		o.format, o.columns = "table", splitList(strings.TrimPrefix(spec, "columns="))
+		if len(o.columns) == 0 {
+			return nil, fmt.Errorf("no columns in output format %q", spec)
+		}
+	case strings.HasPrefix(spec, "jsonpath="):
+		template, err := parseTemplate(strings.TrimPrefix(spec, "jsonpath="))
+		if err != nil {
+			return nil, err
+		}
+		o.format, o.template = "jsonpath", template
+	default:
+		return nil, fmt.Errorf("unknown output format %q", spec)
+	}
+	return o, nil
+}
+
+// print prints networks.
+func (o *output) print(networks []haci.Network) error {
+	if o.format == "json" {
+		if networks == nil {
+			networks = []haci.Network{}
+		}
+		e := json.NewEncoder(o.w)
+		e.SetIndent("", "  ")
+		return e.Encode(networks)
+	}
+
+	rows := [][]string{}
+	for _, n := range networks {
+		fields, err := fieldsOf(n)
+		if err != nil {
+			return err
+		}
+		if o.format == "jsonpath" {
+			rows = append(rows, []string{o.expand(fields)})
+			continue
+		}
+		row := []string{}
+		for _, column := range o.columns {
+			row = append(row, fields[column])
+		}
+		rows = append(rows, row)
+	}
+
+	switch o.format {
+	case "csv":
+		w := csv.NewWriter(o.w)
+		w.Write(o.columns)
+		w.WriteAll(rows)
+		return w.Error()
+	case "jsonpath":
+		for _, row := range rows {
+			if _, err := fmt.Fprintln(o.w, row[0]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	w := tabwriter.NewWriter(o.w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, strings.ToUpper(strings.Join(o.columns, "\t")))
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
+
+// expand replaces the expressions of the jsonpath template with fields.
+func (o *output) expand(fields map[string]string) string {
+	var b strings.Builder
+	for i, part := range o.template {
+		// Odd parts are field names, even parts literal text.
+		if i%2 == 1 {
+			b.WriteString(fields[part])
+		} else {
+			b.WriteString(part)
+		}
+	}
+	return b.String()
+}
+
+// parseTemplate splits a jsonpath template into literal text and field
+// names, alternating and starting with text.
+func parseTemplate(s string) ([]string, error) {
+	parts := []string{}
+	for {
+		start := strings.Index(s, "{")
+		if start < 0 {
+			return append(parts, s), nil
+		}
+		end := strings.Index(s[start:], "}")
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated expression in template %q", s)
+		}
+		expr := s[start+1 : start+end]
+		if !strings.HasPrefix(expr, ".") || len(expr) == 1 {
+			return nil, fmt.Errorf("invalid expression {%s} in template, want {.field}", expr)
+		}
+		parts = append(parts, s[:start], expr[1:])
+		s = s[start+end+1:]
+	}
+}
+
+// fieldsOf returns the fields of n by their JSON names. Lists are joined by
+// commas.
+func fieldsOf(n haci.Network) (map[string]string, error) {
+	b, err := json.Marshal(n)
+	if err != nil {
+		return nil, err
+	}
+	raw := map[string]interface{}{}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, err
+	}
+
+	fields := map[string]string{}
+	for name, value := range raw {
+		switch v := value.(type) {
+		case nil:
+		case []interface{}:
+			s := []string{}
+			for _, e := range v {
+				s = append(s, fmt.Sprint(e))
+			}
+			fields[name] = strings.Join(s, ",")
+		default:
+			fields[name] = fmt.Sprint(v)
+		}
+	}
+	return fields, nil
+}
@@ -0,0 +1,287 @@
+package haci
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Manifest is the desired state of supernets and their networks, e.g.
+//
+//	supernets:
+//	  - network: 10.0.0.0/16
+//	    description: datacenter
+//	    prune: true
+//	    networks:
+//	      - network: 10.0.1.0/24
+//	        description: web
+//	        tags: [prod, web]
+//	      - network: 10.0.2.0/24
+//	        description: db
+//
+// Plan compares it with HaCi and Apply converges HaCi to it.
+type Manifest struct {
+	Supernets []ManifestSupernet `yaml:"supernets"`
+}
+
+// ManifestSupernet is a supernet in a Manifest.
+type ManifestSupernet struct {
+	NetworkSpec `yaml:",inline"`
+
+	// Prune deletes the subnets listed for the supernet that are not in
+	// Networks, together with all networks inside them. Subnets containing
+	// a network of the manifest are kept. Without Prune, other subnets are
+	// left alone.
+	Prune bool `yaml:"prune"`
+
+	Networks []NetworkSpec `yaml:"networks"`
+}
+
+// LoadManifest reads a manifest file and validates it.
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m := &Manifest{}
+	if err := yaml.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("reading %s: %s", path, err.Error())
+	}
+	if err := m.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err.Error())
+	}
+	return m, nil
+}
+
+// Validate checks that all networks and tags are valid, that networks are
+// inside their supernet and that no network appears twice.
+func (m *Manifest) Validate() error {
+	seen := map[string]bool{}
+	check := func(s NetworkSpec) error {
+		if err := s.Validate(); err != nil {
+			return err
+		}
+		if seen[s.Network] {
+			return fmt.Errorf("%s appears twice", s.Network)
+		}
+		seen[s.Network] = true
+		return nil
+	}
+
+	for _, s := range m.Supernets {
+		if err := check(s.NetworkSpec); err != nil {
+			return err
+		}
+		for _, n := range s.Networks {
+			if err := check(n); err != nil {
+				return err
+			}
+			if !containsCIDR(s.Network, n.Network) {
+				return fmt.Errorf("%s is not inside supernet %s", n.Network, s.Network)
+			}
+		}
+	}
+	return nil
+}
+
+// ChangeAction is what a Change does to a network.
+type ChangeAction string
+
+const (
+	ChangeCreate ChangeAction = "create"
+	ChangeUpdate ChangeAction = "update"
+	ChangeDelete ChangeAction = "delete"
+)
+
+// Change is a step of a Plan.
+type Change struct {
+	Action ChangeAction
+
+	// Network is the desired network, or the network to delete.
+	Network NetworkSpec
+
+	// Current is the network before the change, nil for creations.
+	Current *Network
+
+	// Below are the networks inside a network to delete, which are
+	// deleted with it, the smallest first.
+	Below []Network
+}
+
+func (c Change) String() string {
+	switch c.Action {
+	case ChangeCreate:
+		return fmt.Sprintf("+ %s %q %q", c.Network.Network, c.Network.Description, c.Network.Tags)
+	case ChangeDelete:
+		lines := []string{fmt.Sprintf("- %s %q %q", c.Network.Network, c.Network.Description, c.Network.Tags)}
+		for _, n := range c.Below {
+			lines = append(lines, fmt.Sprintf("  - %s %q %q", n.Network, n.Description, n.Tags))
+		}
+		return strings.Join(lines, "\n")
+	}
+	return fmt.Sprintf("~ %s %q %q -> %q %q", c.Network.Network,
+		c.Current.Description, c.Current.Tags, c.Network.Description, c.Network.Tags)
+}
+
+// Plan is the list of changes that converge HaCi to a Manifest, in the
+// order they are applied: deletions, then creations with supernets before
+// their subnets, then updates.
+type Plan struct {
+	Changes []Change
+}
+
+// Empty reports whether HaCi matches the manifest already.
+func (p *Plan) Empty() bool {
+	return len(p.Changes) == 0
+}
+
+// String returns the changes one per line, marked with + for creations,
+// ~ for updates and - for deletions. The networks deleted with a network
+// follow it indented.
+func (p *Plan) String() string {
+	lines := []string{}
+	for _, c := range p.Changes {
+		lines = append(lines, c.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// PlanManifest compares m with the networks in HaCi and returns the changes
+// needed to converge them, without changing anything.
+func PlanManifest(ctx context.Context, c Client, m *Manifest) (*Plan, error) {
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+
+	desired := []NetworkSpec{}
+	for _, s := range m.Supernets {
+		desired = append(desired, s.NetworkSpec)
+		desired = append(desired, s.Networks...)
+	}
+	networks := []string{}
+	for _, s := range desired {
+		networks = append(networks, s.Network)
+	}
+	current, err := c.GetMany(ctx, networks, 0)
+	var bulkErr *BulkError
+	if errors.As(err, &bulkErr) {
+		for network, err := range bulkErr.Failed {
+			if !errors.Is(err, ErrNotFound) {
+				return nil, fmt.Errorf("getting %s: %w", network, err)
+			}
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	var deletes, creates, updates []Change
+	for _, s := range desired {
+		n, ok := current[s.Network]
+		switch {
+		case !ok:
+			creates = append(creates, Change{Action: ChangeCreate, Network: s})
+		case n.Description != s.Description || !sameTags(n.Tags, s.Tags):
+			n := n
+			updates = append(updates, Change{Action: ChangeUpdate, Network: s, Current: &n})
+		}
+	}
+
+	for _, s := range m.Supernets {
+		if _, ok := current[s.Network]; !ok || !s.Prune {
+			continue
+		}
+		keep := map[string]bool{}
+		for _, n := range s.Networks {
+			keep[n.Network] = true
+		}
+		listed, err := c.List(ctx, s.Network)
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range listed {
+			if keep[n.Network] || n.Network == s.Network || containsAny(n.Network, networks) {
+				continue
+			}
+			deleted, err := c.DeleteRecursive(ctx, n.Network, true)
+			if err != nil {
+				return nil, err
+			}
+			below := []Network{}
+			for _, d := range deleted {
+				if d.Network != n.Network {
+					below = append(below, d)
+				}
+			}
+			n := n
+			spec := NetworkSpec{Network: n.Network, Description: n.Description, Tags: n.Tags}
+			deletes = append(deletes, Change{Action: ChangeDelete, Network: spec, Current: &n, Below: below})
+		}
+	}
+
+	byCIDR := func(changes []Change) {
+		sort.Slice(changes, func(i, j int) bool {
+			return compareCIDR(changes[i].Network.Network, changes[j].Network.Network) < 0
+		})
+	}
+	byCIDR(deletes)
+	byCIDR(creates)
+	byCIDR(updates)
+	// Subnets are deleted before the networks containing them.
+	for i, j := 0, len(deletes)-1; i < j; i, j = i+1, j-1 {
+		deletes[i], deletes[j] = deletes[j], deletes[i]
+	}
+
+	plan := &Plan{}
+	plan.Changes = append(plan.Changes, deletes...)
+	plan.Changes = append(plan.Changes, creates...)
+	plan.Changes = append(plan.Changes, updates...)
+	return plan, nil
+}
+
+// containsAny reports whether network contains one of networks.
+func containsAny(network string, networks []string) bool {
+	for _, n := range networks {
+		if containsCIDR(network, n) {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyPlan makes the changes of p in order. It stops at the first change
+// that fails; planning again afterwards returns the remaining changes.
+func ApplyPlan(ctx context.Context, c Client, p *Plan) error {
+	for _, change := range p.Changes {
+		var err error
+		n := change.Network
+		switch change.Action {
+		case ChangeCreate:
+			err = c.Add(ctx, n.Network, n.Description, n.Tags)
+		case ChangeUpdate:
+			err = c.Update(ctx, n.Network, n.Description, n.Tags)
+		case ChangeDelete:
+			_, err = c.DeleteRecursive(ctx, n.Network, false)
+		default:
+			err = fmt.Errorf("unknown action %q", change.Action)
+		}
+		if err != nil {
+			return fmt.Errorf("%s %s: %w", change.Action, n.Network, err)
+		}
+	}
+	return nil
+}
+
+// Apply converges HaCi to m and returns the changes it made. If a change
+// fails, the plan is returned with the error.
+func Apply(ctx context.Context, c Client, m *Manifest) (*Plan, error) {
+	plan, err := PlanManifest(ctx, c, m)
+	if err != nil {
+		return nil, err
+	}
+	return plan, ApplyPlan(ctx, c, plan)
+}
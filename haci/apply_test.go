@@ -0,0 +1,68 @@
+package haci_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/Nexinto/go-haci-client/haci"
+)
+
+func TestApplyPrune(t *testing.T) {
+	ctx := context.Background()
+	c := haci.NewFakeClient()
+	for _, n := range []string{
+		"10.0.0.0/16",
+		"10.0.0.0/20", "10.0.1.0/24", "10.0.2.0/24",
+		"10.0.16.0/20", "10.0.16.0/24", "10.0.16.0/28", "10.0.17.0/24",
+	} {
+		if err := c.Add(ctx, n, "existing", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := &haci.Manifest{Supernets: []haci.ManifestSupernet{{
+		NetworkSpec: haci.NetworkSpec{Network: "10.0.0.0/16", Description: "existing"},
+		Prune:       true,
+		Networks:    []haci.NetworkSpec{{Network: "10.0.1.0/24", Description: "existing"}},
+	}}}
+	plan, err := haci.PlanManifest(ctx, c, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 10.0.0.0/20 contains 10.0.1.0/24 and is kept.
+	if len(plan.Changes) != 1 || plan.Changes[0].Action != haci.ChangeDelete || plan.Changes[0].Network.Network != "10.0.16.0/20" {
+		t.Fatalf("got plan\n%s\nwant to delete 10.0.16.0/20 only", plan)
+	}
+	below := []string{}
+	for _, n := range plan.Changes[0].Below {
+		below = append(below, n.Network)
+	}
+	if want := []string{"10.0.16.0/28", "10.0.16.0/24", "10.0.17.0/24"}; !reflect.DeepEqual(below, want) {
+		t.Errorf("networks deleted with 10.0.16.0/20 are %q, want %q", below, want)
+	}
+
+	if err := haci.ApplyPlan(ctx, c, plan); err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []string{"10.0.0.0/16", "10.0.0.0/20", "10.0.1.0/24", "10.0.2.0/24"} {
+		if _, err := c.Get(ctx, n); err != nil {
+			t.Errorf("%s was deleted: %s", n, err)
+		}
+	}
+	for _, n := range []string{"10.0.16.0/20", "10.0.16.0/24", "10.0.16.0/28", "10.0.17.0/24"} {
+		if _, err := c.Get(ctx, n); !errors.Is(err, haci.ErrNotFound) {
+			t.Errorf("%s was not deleted: %v", n, err)
+		}
+	}
+
+	plan, err = haci.PlanManifest(ctx, c, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !plan.Empty() {
+		t.Errorf("got plan\n%s\nafter applying, want none", plan)
+	}
+}
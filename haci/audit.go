@@ -0,0 +1,79 @@
+package haci
+
+import (
+	"context"
+	neturl "net/url"
+	"time"
+)
+
+// AuditEvent describes a request that changed or tried to change data in
+// HaCi.
+type AuditEvent struct {
+	Time time.Time
+	// Endpoint is the RESTWrapper endpoint called, e.g. "addNet".
+	Endpoint string
+	Root     string
+	// Params are the parameters sent to the endpoint.
+	Params neturl.Values
+	// Caller identifies who made the change, see ContextWithCaller. It
+	// defaults to the username of the client.
+	Caller string
+	// Status is the HTTP status of the response, 0 if there was none.
+	Status int
+	// Response is the response body.
+	Response string
+	// Err is the error of the request, nil if it succeeded.
+	Err error
+}
+
+// AuditSink receives audit events. Audit is called synchronously after
+// every mutating request, so slow sinks should buffer.
+type AuditSink interface {
+	Audit(ctx context.Context, event AuditEvent)
+}
+
+// AuditFunc adapts a function to an AuditSink.
+type AuditFunc func(ctx context.Context, event AuditEvent)
+
+func (f AuditFunc) Audit(ctx context.Context, event AuditEvent) { f(ctx, event) }
+
+// WithAudit sends an AuditEvent to sink for every request that changes
+// data, whether it succeeded or not. Requests rejected by WithReadOnly and
+// changes only planned with WithDryRun are not audited.
+func WithAudit(sink AuditSink) Option {
+	return func(c *clientConfig) error {
+		c.audit = sink
+		return nil
+	}
+}
+
+type callerKey struct{}
+
+// ContextWithCaller returns a context that attributes changes made with it
+// to caller in audit events.
+func ContextWithCaller(ctx context.Context, caller string) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+func (c *WebClient) auditRequest(ctx context.Context, start time.Time, req *Request, resp *Response, err error) {
+	if c.audit == nil {
+		return
+	}
+	caller, ok := ctx.Value(callerKey{}).(string)
+	if !ok {
+		caller = c.username
+	}
+	event := AuditEvent{
+		Time:     start,
+		Endpoint: req.Endpoint,
+		Root:     c.Root,
+		Params:   req.Params,
+		Caller:   caller,
+		Err:      err,
+	}
+	if resp != nil {
+		event.Status = resp.Status()
+		event.Response = resp.RawText()
+	}
+	c.audit.Audit(ctx, event)
+}
@@ -0,0 +1,377 @@
+package haci
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+
+	ccidr "github.com/apparentlymart/go-cidr/cidr"
+)
+
+// AssignMany assigns count subnets of the same size from supernet. If not
+// all of them can be assigned, the ones already assigned are deleted again
+// and the error is returned.
+func (c *WebClient) AssignMany(ctx context.Context, supernet, description string, cidr, count int, tags []string) (networks []Network, err error) {
+	ctx, end := c.operation(ctx, "AssignMany", "supernet", supernet)
+	defer end(&err)
+
+	return assignMany(ctx, c, supernet, description, cidr, count, tags)
+}
+
+func (c *FakeClient) AssignMany(ctx context.Context, supernet, description string, cidr, count int, tags []string) ([]Network, error) {
+	return assignMany(ctx, c, supernet, description, cidr, count, tags)
+}
+
+func assignMany(ctx context.Context, c Client, supernet, description string, cidr, count int, tags []string) ([]Network, error) {
+	assigned := []Network{}
+	for i := 0; i < count; i++ {
+		n, err := c.Assign(ctx, supernet, description, cidr, tags)
+		if err != nil {
+			if rollbackErr := rollback(ctx, c, assigned); rollbackErr != nil {
+				return nil, fmt.Errorf("%s; rollback failed: %s", err.Error(), rollbackErr.Error())
+			}
+			return nil, err
+		}
+		assigned = append(assigned, n)
+	}
+	return assigned, nil
+}
+
+// AssignBlock assigns count contiguous subnets with prefix length cidr from
+// supernet. They are taken from the first free aligned block that can hold
+// them, so together they can be summarized into a single route. If not all
+// of them can be created, the ones already created are deleted again.
+func (c *WebClient) AssignBlock(ctx context.Context, supernet, description string, cidr, count int, tags []string) (networks []Network, err error) {
+	ctx, end := c.operation(ctx, "AssignBlock", "supernet", supernet)
+	defer end(&err)
+
+	return assignBlock(ctx, c, supernet, description, cidr, count, tags)
+}
+
+func (c *FakeClient) AssignBlock(ctx context.Context, supernet, description string, cidr, count int, tags []string) ([]Network, error) {
+	return assignBlock(ctx, c, supernet, description, cidr, count, tags)
+}
+
+func assignBlock(ctx context.Context, c Client, supernet, description string, cidr, count int, tags []string) ([]Network, error) {
+	if count < 1 {
+		return nil, fmt.Errorf("count must be at least 1")
+	}
+
+	// The smallest aligned block holding count subnets.
+	blockBits := 0
+	for 1<<uint(blockBits) < count {
+		blockBits++
+	}
+
+	free, err := c.FreeSubnets(ctx, supernet, cidr-blockBits)
+	if err != nil {
+		return nil, err
+	}
+	if len(free) == 0 {
+		return nil, newError(ErrNoFreeSubnet, "no free block of %d /%d in %s", count, cidr, supernet)
+	}
+
+	_, first, _ := net.ParseCIDR(free[0])
+	ones, _ := first.Mask.Size()
+	block, err := ccidr.Subnet(first, cidr-blockBits-ones, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	assigned := []Network{}
+	for i := 0; i < count; i++ {
+		subnet, err := ccidr.Subnet(block, blockBits, i)
+		if err == nil {
+			err = c.Add(ctx, subnet.String(), description, tags)
+		}
+		if err != nil {
+			if rollbackErr := rollback(ctx, c, assigned); rollbackErr != nil {
+				return nil, fmt.Errorf("%s; rollback failed: %s", err.Error(), rollbackErr.Error())
+			}
+			return nil, err
+		}
+		assigned = append(assigned, Network{Network: subnet.String(), Description: description, Tags: tags})
+	}
+	return assigned, nil
+}
+
+// CandidateOrder is the order in which AssignFromAny tries supernets.
+type CandidateOrder int
+
+const (
+	// InOrder tries the supernets in the order given.
+	InOrder CandidateOrder = iota
+
+	// MostFree tries the supernets with the most free addresses first.
+	MostFree
+)
+
+// AssignFromAny assigns a subnet from the first of supernets that has
+// room for it. Supernets without a free subnet are skipped; other errors
+// are returned immediately.
+func (c *WebClient) AssignFromAny(ctx context.Context, supernets []string, order CandidateOrder, description string, cidr int, tags []string) (network1 Network, err error) {
+	ctx, end := c.operation(ctx, "AssignFromAny", "supernet", strings.Join(supernets, " "))
+	defer end(&err)
+
+	return assignFromAny(ctx, c, supernets, order, description, cidr, tags)
+}
+
+func (c *FakeClient) AssignFromAny(ctx context.Context, supernets []string, order CandidateOrder, description string, cidr int, tags []string) (Network, error) {
+	return assignFromAny(ctx, c, supernets, order, description, cidr, tags)
+}
+
+func assignFromAny(ctx context.Context, c Client, supernets []string, order CandidateOrder, description string, cidr int, tags []string) (Network, error) {
+	candidates := append([]string{}, supernets...)
+
+	if order == MostFree {
+		free := map[string]*big.Int{}
+		for _, s := range candidates {
+			u, err := c.Utilization(ctx, s)
+			if err != nil {
+				return Network{}, err
+			}
+			free[s] = u.Free
+		}
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return free[candidates[i]].Cmp(free[candidates[j]]) > 0
+		})
+	}
+
+	for _, s := range candidates {
+		n, err := c.Assign(ctx, s, description, cidr, tags)
+		if errors.Is(err, ErrNoFreeSubnet) {
+			continue
+		}
+		return n, err
+	}
+	return Network{}, newError(ErrNoFreeSubnet, "no free /%d in any of %v", cidr, supernets)
+}
+
+// AssignOrGet returns the network in supernet with exactly this
+// description, and assigns a new one only if there is none. This makes
+// assignments keyed by description idempotent.
+func (c *WebClient) AssignOrGet(ctx context.Context, supernet, description string, cidr int, tags []string) (network1 Network, err error) {
+	ctx, end := c.operation(ctx, "AssignOrGet", "supernet", supernet)
+	defer end(&err)
+
+	return assignOrGet(ctx, c, supernet, description, cidr, tags)
+}
+
+func (c *FakeClient) AssignOrGet(ctx context.Context, supernet, description string, cidr int, tags []string) (Network, error) {
+	return assignOrGet(ctx, c, supernet, description, cidr, tags)
+}
+
+func assignOrGet(ctx context.Context, c Client, supernet, description string, cidr int, tags []string) (Network, error) {
+	found, err := c.Search(ctx, description, true)
+	if err != nil {
+		return Network{}, err
+	}
+
+	sort.Slice(found, func(i, j int) bool { return compareCIDR(found[i].Network, found[j].Network) < 0 })
+	for _, n := range found {
+		if n.Description != description || !containsCIDR(supernet, n.Network) {
+			continue
+		}
+		if prefixLen(n.Network) != cidr {
+			return Network{}, fmt.Errorf("%s with description %q exists, but is not a /%d", n.Network, description, cidr)
+		}
+		return n, nil
+	}
+
+	return c.Assign(ctx, supernet, description, cidr, tags)
+}
+
+// NetworkSpec describes a network to be added.
+type NetworkSpec struct {
+	Network     string   `yaml:"network"`
+	Description string   `yaml:"description"`
+	Tags        []string `yaml:"tags"`
+}
+
+// Validate checks the network and tags of s, so that a batch of specs can
+// be checked before any of them is sent to HaCi.
+func (s NetworkSpec) Validate() error {
+	if err := validateNetwork(s.Network); err != nil {
+		return err
+	}
+	return validateTags(s.Tags, false)
+}
+
+// BulkError is returned by bulk operations if some of the networks failed.
+type BulkError struct {
+	// Total is the number of networks in the operation.
+	Total int
+
+	// Failed maps the networks that failed to their error. BulkAdd and
+	// BulkDelete reject lists with duplicate networks, and GetMany gets
+	// each network once, so every network has one entry.
+	Failed map[string]error
+}
+
+func (e *BulkError) Error() string {
+	return fmt.Sprintf("%d of %d networks failed", len(e.Failed), e.Total)
+}
+
+// BulkAdd adds many networks, sending up to concurrency requests in
+// parallel. If concurrency is 0 or less, the client default is used. All
+// networks are tried; if some fail, a *BulkError lists them. If a network
+// appears more than once, nothing is added.
+func (c *WebClient) BulkAdd(ctx context.Context, specs []NetworkSpec, concurrency int) (err error) {
+	ctx, end := c.operation(ctx, "BulkAdd")
+	defer end(&err)
+
+	if concurrency <= 0 {
+		concurrency = c.concurrency
+	}
+	return bulk(ctx, specNetworks(specs), concurrency, func(i int) error {
+		return c.Add(ctx, specs[i].Network, specs[i].Description, specs[i].Tags)
+	})
+}
+
+// BulkDelete deletes many networks like BulkAdd adds them.
+func (c *WebClient) BulkDelete(ctx context.Context, networks []string, concurrency int) (err error) {
+	ctx, end := c.operation(ctx, "BulkDelete")
+	defer end(&err)
+
+	if concurrency <= 0 {
+		concurrency = c.concurrency
+	}
+	return bulk(ctx, networks, concurrency, func(i int) error {
+		return c.Delete(ctx, networks[i])
+	})
+}
+
+// BulkAdd adds the networks one after the other; concurrency is ignored.
+func (c *FakeClient) BulkAdd(ctx context.Context, specs []NetworkSpec, concurrency int) error {
+	return bulk(ctx, specNetworks(specs), 1, func(i int) error {
+		return c.Add(ctx, specs[i].Network, specs[i].Description, specs[i].Tags)
+	})
+}
+
+// BulkDelete deletes the networks one after the other; concurrency is ignored.
+func (c *FakeClient) BulkDelete(ctx context.Context, networks []string, concurrency int) error {
+	return bulk(ctx, networks, 1, func(i int) error {
+		return c.Delete(ctx, networks[i])
+	})
+}
+
+// GetMany gets many networks, sending up to concurrency requests in
+// parallel, like BulkAdd. It returns the networks found by their CIDR; if
+// some could not be fetched, a *BulkError lists them with their errors,
+// e.g. ErrNotFound. Networks may appear more than once; each is fetched
+// once.
+func (c *WebClient) GetMany(ctx context.Context, networks []string, concurrency int) (found map[string]Network, err error) {
+	ctx, end := c.operation(ctx, "GetMany")
+	defer end(&err)
+
+	if concurrency <= 0 {
+		concurrency = c.concurrency
+	}
+	return getMany(ctx, c, networks, concurrency)
+}
+
+// GetMany gets the networks one after the other; concurrency is ignored.
+func (c *FakeClient) GetMany(ctx context.Context, networks []string, concurrency int) (map[string]Network, error) {
+	return getMany(ctx, c, networks, 1)
+}
+
+func getMany(ctx context.Context, c Client, requested []string, parallel int) (map[string]Network, error) {
+	networks := []string{}
+	seen := map[string]bool{}
+	for _, n := range requested {
+		if !seen[n] {
+			seen[n] = true
+			networks = append(networks, n)
+		}
+	}
+
+	found := make([]Network, len(networks))
+	err := bulk(ctx, networks, parallel, func(i int) error {
+		n, err := c.Get(ctx, networks[i])
+		found[i] = n
+		return err
+	})
+
+	result := map[string]Network{}
+	for i, n := range found {
+		if n.Network != "" {
+			result[networks[i]] = n
+		}
+	}
+	return result, err
+}
+
+func specNetworks(specs []NetworkSpec) []string {
+	networks := make([]string, len(specs))
+	for i, s := range specs {
+		networks[i] = s.Network
+	}
+	return networks
+}
+
+// bulk calls op for every index of networks with up to parallel calls at
+// the same time, and collects the errors. It fails without calling op if a
+// network appears more than once.
+func bulk(ctx context.Context, networks []string, parallel int, op func(i int) error) error {
+	seen := map[string]bool{}
+	for _, n := range networks {
+		if seen[n] {
+			return fmt.Errorf("%s appears more than once", n)
+		}
+		seen[n] = true
+	}
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	errs := make([]error, len(networks))
+
+	sem := make(chan struct{}, parallel)
+	wg := sync.WaitGroup{}
+	for i := range networks {
+		if ctx.Err() != nil {
+			errs[i] = ctx.Err()
+			continue
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int) {
+			defer func() { <-sem; wg.Done() }()
+			errs[i] = op(i)
+		}(i)
+	}
+	wg.Wait()
+
+	failed := map[string]error{}
+	for i, err := range errs {
+		if err != nil {
+			failed[networks[i]] = err
+		}
+	}
+	if len(failed) > 0 {
+		return &BulkError{Total: len(networks), Failed: failed}
+	}
+	return nil
+}
+
+// rollback deletes networks assigned by a batch that failed. It continues
+// even if ctx was cancelled, which may have been the cause of the failure.
+func rollback(ctx context.Context, c Client, networks []Network) error {
+	ctx = context.WithoutCancel(ctx)
+
+	var failed []string
+	for _, n := range networks {
+		if err := c.Delete(ctx, n.Network); err != nil {
+			failed = append(failed, n.Network)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("could not delete %v", failed)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package haci_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Nexinto/go-haci-client/haci"
+	"github.com/Nexinto/go-haci-client/haci/hacitest"
+)
+
+func TestBulkNegativeConcurrency(t *testing.T) {
+	ctx := context.Background()
+	s := hacitest.NewServer("test")
+	defer s.Close()
+	c, err := haci.NewWebClient(s.URL, haci.WithRoot("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	specs := []haci.NetworkSpec{{Network: "10.0.0.0/24"}, {Network: "10.0.1.0/24"}}
+	if err := c.BulkAdd(ctx, specs, -1); err != nil {
+		t.Fatalf("BulkAdd: %s", err)
+	}
+	found, err := c.GetMany(ctx, []string{"10.0.0.0/24", "10.0.1.0/24"}, -1)
+	if err != nil || len(found) != 2 {
+		t.Fatalf("GetMany: got %d networks, error %v", len(found), err)
+	}
+	if err := c.BulkDelete(ctx, []string{"10.0.0.0/24", "10.0.1.0/24"}, -5); err != nil {
+		t.Fatalf("BulkDelete: %s", err)
+	}
+}
+
+func TestBulkDuplicates(t *testing.T) {
+	ctx := context.Background()
+	s := hacitest.NewServer("test")
+	defer s.Close()
+	web, err := haci.NewWebClient(s.URL, haci.WithRoot("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, c := range map[string]haci.Client{"WebClient": web, "FakeClient": haci.NewFakeClient()} {
+		specs := []haci.NetworkSpec{
+			{Network: "10.0.0.0/24", Description: "first"},
+			{Network: "10.0.1.0/24"},
+			{Network: "10.0.0.0/24", Description: "second"},
+		}
+		err := c.BulkAdd(ctx, specs, 2)
+		var bulkErr *haci.BulkError
+		if err == nil || errors.As(err, &bulkErr) {
+			t.Errorf("%s: got error %v, want duplicates rejected", name, err)
+		}
+		if _, err := c.Get(ctx, "10.0.1.0/24"); !errors.Is(err, haci.ErrNotFound) {
+			t.Errorf("%s: networks were added despite duplicates: %v", name, err)
+		}
+	}
+}
+
+func TestBulkErrorPerNetwork(t *testing.T) {
+	ctx := context.Background()
+	c := haci.NewFakeClient()
+	if err := c.Add(ctx, "10.0.0.0/24", "existing", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	err := c.BulkAdd(ctx, []haci.NetworkSpec{{Network: "10.0.0.0/24"}, {Network: "10.0.1.0/24"}}, 0)
+	var bulkErr *haci.BulkError
+	if !errors.As(err, &bulkErr) {
+		t.Fatalf("got error %v, want a *BulkError", err)
+	}
+	if bulkErr.Total != 2 || len(bulkErr.Failed) != 1 || !errors.Is(bulkErr.Failed["10.0.0.0/24"], haci.ErrAlreadyExists) {
+		t.Errorf("got %d of %d failed: %v", len(bulkErr.Failed), bulkErr.Total, bulkErr.Failed)
+	}
+}
+
+func TestGetManyDuplicates(t *testing.T) {
+	ctx := context.Background()
+	s := hacitest.NewServer("test")
+	defer s.Close()
+	for _, n := range []string{"10.0.0.0/24", "10.0.1.0/24"} {
+		if err := s.Fake("test").Add(ctx, n, "", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c, err := haci.NewWebClient(s.URL, haci.WithRoot("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requested := []string{"10.0.0.0/24", "10.0.1.0/24", "10.0.0.0/24", "10.0.9.0/24", "10.0.1.0/24", "10.0.9.0/24"}
+	found, err := c.GetMany(ctx, requested, 2)
+	var bulkErr *haci.BulkError
+	if !errors.As(err, &bulkErr) || bulkErr.Total != 3 || len(bulkErr.Failed) != 1 || !errors.Is(bulkErr.Failed["10.0.9.0/24"], haci.ErrNotFound) {
+		t.Errorf("got error %v, want 10.0.9.0/24 not found", err)
+	}
+	if len(found) != 2 || found["10.0.0.0/24"].Network != "10.0.0.0/24" || found["10.0.1.0/24"].Network != "10.0.1.0/24" {
+		t.Errorf("found %v", found)
+	}
+	gets := 0
+	for _, r := range s.Requests() {
+		if r.Endpoint == "getNetworkDetails" {
+			gets++
+		}
+	}
+	if gets != 3 {
+		t.Errorf("sent %d requests for 3 networks", gets)
+	}
+}
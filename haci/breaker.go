@@ -0,0 +1,106 @@
+package haci
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without contacting the server while the circuit
+// breaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker is open, HaCi is unavailable")
+
+// WithCircuitBreaker makes the client fail fast with ErrCircuitOpen after
+// threshold consecutive calls failed with connection or server errors.
+// After cooldown, up to probes calls are let through; if all of them
+// succeed the breaker closes again, otherwise it stays open for another cooldown.
+func WithCircuitBreaker(threshold int, cooldown time.Duration, probes int) Option {
+	return func(c *clientConfig) error {
+		if threshold < 1 || probes < 1 {
+			return fmt.Errorf("circuit breaker needs a positive threshold and number of probes")
+		}
+		c.breaker = &circuitBreaker{threshold: threshold, cooldown: cooldown, probes: probes}
+		return nil
+	}
+}
+
+const (
+	breakerClosed = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	probes    int
+
+	mu        sync.Mutex
+	state     int
+	failures  int
+	openedAt  time.Time
+	inFlight  int
+	successes int
+}
+
+// allow reports whether a call may proceed and whether it is a probe
+// whose result decides if the breaker closes again.
+func (b *circuitBreaker) allow() (ok, probe bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerClosed:
+		return true, false
+	case breakerOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return false, false
+		}
+		b.state, b.inFlight, b.successes = breakerHalfOpen, 0, 0
+	}
+
+	if b.inFlight+b.successes >= b.probes {
+		return false, false
+	}
+	b.inFlight++
+	return true, true
+}
+
+// record registers the outcome of a call admitted by allow.
+func (b *circuitBreaker) record(probe, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch {
+	case b.state == breakerClosed && !probe:
+		if !failed {
+			b.failures = 0
+			return
+		}
+		b.failures++
+		if b.failures >= b.threshold {
+			b.state, b.openedAt = breakerOpen, time.Now()
+		}
+	case b.state == breakerHalfOpen && probe:
+		b.inFlight--
+		if failed {
+			b.state, b.openedAt = breakerOpen, time.Now()
+			return
+		}
+		b.successes++
+		if b.successes >= b.probes {
+			b.state, b.failures = breakerClosed, 0
+		}
+	}
+}
+
+// release gives back a probe slot without recording an outcome.
+func (b *circuitBreaker) release(probe bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if probe && b.state == breakerHalfOpen {
+		b.inFlight--
+	}
+}
@@ -0,0 +1,227 @@
+package haci
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CachingClient caches the results of Get, List and Search of another
+// Client for a fixed time. Changes made through the CachingClient
+// invalidate the affected entries; changes made by others become visible
+// when the entries expire. Expired entries are removed from time to time,
+// and callers get copies of the cached networks they may change.
+type CachingClient struct {
+	Client
+
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	// sweep is when the expired entries are removed next.
+	sweep time.Time
+}
+
+type cacheEntry struct {
+	value   interface{}
+	expires time.Time
+}
+
+// NewCachingClient returns a client that caches the reads of inner for ttl.
+func NewCachingClient(inner Client, ttl time.Duration) *CachingClient {
+	return &CachingClient{Client: inner, ttl: ttl, entries: map[string]cacheEntry{}}
+}
+
+func (c *CachingClient) lookup(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok || time.Now().After(e.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return e.value, true
+}
+
+func (c *CachingClient) store(key string, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if now.After(c.sweep) {
+		for k, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		c.sweep = now.Add(c.ttl)
+	}
+	c.entries[key] = cacheEntry{value: value, expires: now.Add(c.ttl)}
+}
+
+// invalidate removes the entries a change of networks can affect: the
+// networks themselves, listings of supernets containing them and all
+// searches.
+func (c *CachingClient) invalidate(networks ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.entries {
+		kind, arg, _ := strings.Cut(key, ":")
+		switch kind {
+		case "search":
+			delete(c.entries, key)
+		case "get", "list":
+			for _, n := range networks {
+				if arg == n || kind == "list" && containsCIDR(arg, n) {
+					delete(c.entries, key)
+					break
+				}
+			}
+		}
+	}
+}
+
+// Flush removes all cached entries.
+func (c *CachingClient) Flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = map[string]cacheEntry{}
+}
+
+func (c *CachingClient) Get(ctx context.Context, network string) (Network, error) {
+	if v, ok := c.lookup("get:" + network); ok {
+		return copyNetwork(v.(Network)), nil
+	}
+	n, err := c.Client.Get(ctx, network)
+	if err != nil {
+		return Network{}, err
+	}
+	c.store("get:"+network, copyNetwork(n))
+	return n, nil
+}
+
+func (c *CachingClient) List(ctx context.Context, supernet string) ([]Network, error) {
+	if v, ok := c.lookup("list:" + supernet); ok {
+		return copyNetworks(v.([]Network)), nil
+	}
+	networks, err := c.Client.List(ctx, supernet)
+	if err != nil {
+		return nil, err
+	}
+	c.store("list:"+supernet, copyNetworks(networks))
+	return networks, nil
+}
+
+func (c *CachingClient) Search(ctx context.Context, description string, exact bool) ([]Network, error) {
+	key := fmt.Sprintf("search:%t:%s", exact, description)
+	if v, ok := c.lookup(key); ok {
+		return copyNetworks(v.([]Network)), nil
+	}
+	networks, err := c.Client.Search(ctx, description, exact)
+	if err != nil {
+		return nil, err
+	}
+	c.store(key, copyNetworks(networks))
+	return networks, nil
+}
+
+func (c *CachingClient) Assign(ctx context.Context, supernet, description string, cidr int, tags []string) (Network, error) {
+	n, err := c.Client.Assign(ctx, supernet, description, cidr, tags)
+	c.invalidate(n.Network)
+	return n, err
+}
+
+func (c *CachingClient) AssignMany(ctx context.Context, supernet, description string, cidr, count int, tags []string) ([]Network, error) {
+	networks, err := c.Client.AssignMany(ctx, supernet, description, cidr, count, tags)
+	c.invalidate(supernet)
+	return networks, err
+}
+
+func (c *CachingClient) AssignBlock(ctx context.Context, supernet, description string, cidr, count int, tags []string) ([]Network, error) {
+	networks, err := c.Client.AssignBlock(ctx, supernet, description, cidr, count, tags)
+	c.invalidate(supernet)
+	return networks, err
+}
+
+func (c *CachingClient) AssignOrGet(ctx context.Context, supernet, description string, cidr int, tags []string) (Network, error) {
+	n, err := c.Client.AssignOrGet(ctx, supernet, description, cidr, tags)
+	c.invalidate(n.Network)
+	return n, err
+}
+
+func (c *CachingClient) AssignFromAny(ctx context.Context, supernets []string, order CandidateOrder, description string, cidr int, tags []string) (Network, error) {
+	n, err := c.Client.AssignFromAny(ctx, supernets, order, description, cidr, tags)
+	c.invalidate(append([]string{n.Network}, supernets...)...)
+	return n, err
+}
+
+func (c *CachingClient) Add(ctx context.Context, network, description string, tags []string) error {
+	defer c.invalidate(network)
+	return c.Client.Add(ctx, network, description, tags)
+}
+
+func (c *CachingClient) Update(ctx context.Context, network, description string, tags []string) error {
+	defer c.invalidate(network)
+	return c.Client.Update(ctx, network, description, tags)
+}
+
+func (c *CachingClient) EnsureNetwork(ctx context.Context, network, description string, tags []string) error {
+	defer c.invalidate(network)
+	return c.Client.EnsureNetwork(ctx, network, description, tags)
+}
+
+func (c *CachingClient) AddTags(ctx context.Context, network string, tags []string) error {
+	defer c.invalidate(network)
+	return c.Client.AddTags(ctx, network, tags)
+}
+
+func (c *CachingClient) RemoveTags(ctx context.Context, network string, tags []string) error {
+	defer c.invalidate(network)
+	return c.Client.RemoveTags(ctx, network, tags)
+}
+
+func (c *CachingClient) Delete(ctx context.Context, network string) error {
+	defer c.invalidate(network)
+	return c.Client.Delete(ctx, network)
+}
+
+func (c *CachingClient) DeleteWithOptions(ctx context.Context, network string, opts DeleteOptions) error {
+	defer c.invalidate(network)
+	return c.Client.DeleteWithOptions(ctx, network, opts)
+}
+
+func (c *CachingClient) DeleteRecursive(ctx context.Context, network string, dryRun bool) ([]Network, error) {
+	defer c.Flush()
+	return c.Client.DeleteRecursive(ctx, network, dryRun)
+}
+
+func (c *CachingClient) Move(ctx context.Context, network, targetRoot string, recursive bool) error {
+	defer c.Flush()
+	return c.Client.Move(ctx, network, targetRoot, recursive)
+}
+
+func (c *CachingClient) Import(ctx context.Context, r io.Reader, opts ImportOptions) (ImportResult, error) {
+	defer c.Flush()
+	return c.Client.Import(ctx, r, opts)
+}
+
+func (c *CachingClient) BulkAdd(ctx context.Context, specs []NetworkSpec, concurrency int) error {
+	defer c.Flush()
+	return c.Client.BulkAdd(ctx, specs, concurrency)
+}
+
+func (c *CachingClient) BulkDelete(ctx context.Context, networks []string, concurrency int) error {
+	defer c.invalidate(networks...)
+	return c.Client.BulkDelete(ctx, networks, concurrency)
+}
+
+func (c *CachingClient) DeleteRoot(ctx context.Context, name string) error {
+	defer c.Flush()
+	return c.Client.DeleteRoot(ctx, name)
+}
+
+func (c *CachingClient) Reset(ctx context.Context) error {
+	defer c.Flush()
+	return c.Client.Reset(ctx)
+}
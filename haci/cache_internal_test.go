@@ -0,0 +1,27 @@
+package haci
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCachingClientEvictsExpired(t *testing.T) {
+	ctx := context.Background()
+	inner := NewFakeClient()
+	if err := inner.Add(ctx, "10.0.0.0/16", "", nil); err != nil {
+		t.Fatal(err)
+	}
+	c := NewCachingClient(inner, time.Millisecond)
+
+	for i := 0; i < 100; i++ {
+		if _, err := c.List(ctx, fmt.Sprintf("10.0.%d.0/24", i)); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if n := len(c.entries); n > 10 {
+		t.Errorf("%d entries are cached, most of them expired", n)
+	}
+}
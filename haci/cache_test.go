@@ -0,0 +1,54 @@
+package haci_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Nexinto/go-haci-client/haci"
+	"github.com/Nexinto/go-haci-client/haci/hacitest"
+)
+
+func TestCachingClientCopiesTags(t *testing.T) {
+	ctx := context.Background()
+	s := hacitest.NewServer("test")
+	defer s.Close()
+	for _, n := range []string{"10.0.0.0/16", "10.0.1.0/24"} {
+		if err := s.Fake("test").Add(ctx, n, "web", []string{"a", "b"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	inner, err := haci.NewWebClient(s.URL, haci.WithRoot("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := haci.NewCachingClient(inner, time.Hour)
+
+	reads := map[string]func() ([]haci.Network, error){
+		"Get": func() ([]haci.Network, error) {
+			n, err := c.Get(ctx, "10.0.1.0/24")
+			return []haci.Network{n}, err
+		},
+		"List":   func() ([]haci.Network, error) { return c.List(ctx, "10.0.0.0/16") },
+		"Search": func() ([]haci.Network, error) { return c.Search(ctx, "web", true) },
+	}
+	for name, read := range reads {
+		// Change the networks both when they are stored and when they
+		// are returned from the cache.
+		for i := 0; i < 2; i++ {
+			networks, err := read()
+			if err != nil || len(networks) == 0 {
+				t.Fatalf("%s: got %v, %v", name, networks, err)
+			}
+			networks[0].Tags[0] = "changed"
+			networks[0].Tags = append(networks[0].Tags, "more")
+		}
+		networks, err := read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tags := networks[0].Tags; len(tags) != 2 || tags[0] != "a" {
+			t.Errorf("%s: cached tags changed to %q", name, tags)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package haci
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Call is a method call recorded by FakeClient.
+type Call struct {
+	// Op is the name of the method, e.g. "Assign".
+	Op string
+
+	// Args are the arguments after the context, in order.
+	Args []interface{}
+
+	// Err is the error the call returned. It is recorded for Assign, Add,
+	// Update and Delete, and for the injected failures of all methods.
+	Err error
+}
+
+func (c Call) String() string {
+	args := []string{}
+	for _, a := range c.Args {
+		args = append(args, fmt.Sprintf("%#v", a))
+	}
+	if c.Err != nil {
+		return fmt.Sprintf("%s(%s) = %s", c.Op, strings.Join(args, ", "), c.Err)
+	}
+	return fmt.Sprintf("%s(%s)", c.Op, strings.Join(args, ", "))
+}
+
+// TestingT is the part of testing.TB used by the assertions of FakeClient.
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// Calls returns the calls of the method op in the order they were made,
+// or all calls if op is empty. Like faults, calls are recorded for the
+// methods that access the networks directly, so AssignMany shows up as
+// a number of calls of Assign.
+func (c *FakeClient) Calls(op string) []Call {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	calls := []Call{}
+	for _, call := range c.calls {
+		if op == "" || call.Op == op {
+			calls = append(calls, *call)
+		}
+	}
+	return calls
+}
+
+// ResetCalls forgets the recorded calls.
+func (c *FakeClient) ResetCalls() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.calls = nil
+}
+
+// AssertCalled checks that the method op was called with arguments
+// starting with args, and reports an error to t otherwise.
+func (c *FakeClient) AssertCalled(t TestingT, op string, args ...interface{}) bool {
+	t.Helper()
+
+	calls := c.Calls(op)
+	for _, call := range calls {
+		if len(call.Args) >= len(args) && reflect.DeepEqual(call.Args[:len(args)], args) {
+			return true
+		}
+	}
+	t.Errorf("%s was not called; calls of %s: %v", Call{Op: op, Args: args}, op, calls)
+	return false
+}
+
+// AssertNotCalled checks that the method op was not called.
+func (c *FakeClient) AssertNotCalled(t TestingT, op string) bool {
+	t.Helper()
+
+	if calls := c.Calls(op); len(calls) > 0 {
+		t.Errorf("%s was called: %v", op, calls)
+		return false
+	}
+	return true
+}
+
+// AssertAssigned checks that a subnet with prefix length cidr was
+// successfully assigned from supernet.
+func (c *FakeClient) AssertAssigned(t TestingT, supernet string, cidr int) bool {
+	t.Helper()
+
+	for _, call := range c.Calls("Assign") {
+		if call.Args[0] == supernet && call.Args[2] == cidr && call.Err == nil {
+			return true
+		}
+	}
+	t.Errorf("no /%d was assigned from %s; calls of Assign: %v", cidr, supernet, c.Calls("Assign"))
+	return false
+}
+
+// AssertAssignAttempted checks that Assign was called for a subnet with
+// prefix length cidr from supernet, whether it succeeded or not.
+func (c *FakeClient) AssertAssignAttempted(t TestingT, supernet string, cidr int) bool {
+	t.Helper()
+
+	for _, call := range c.Calls("Assign") {
+		if call.Args[0] == supernet && call.Args[2] == cidr {
+			return true
+		}
+	}
+	t.Errorf("no /%d was requested from %s; calls of Assign: %v", cidr, supernet, c.Calls("Assign"))
+	return false
+}
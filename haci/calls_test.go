@@ -0,0 +1,62 @@
+package haci_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/Nexinto/go-haci-client/haci"
+)
+
+// recordingT records the errors of assertions.
+type recordingT struct {
+	errors []string
+}
+
+func (t *recordingT) Helper() {}
+
+func (t *recordingT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func TestAssertAssignedIgnoresFailures(t *testing.T) {
+	ctx := context.Background()
+	c := haci.NewFakeClient()
+	if err := c.Add(ctx, "10.0.0.0/16", "", nil); err != nil {
+		t.Fatal(err)
+	}
+	c.SetQuota("10.0.0.0/16", haci.FakeQuota{MaxNetworks: 1})
+	if _, err := c.Assign(ctx, "10.0.0.0/16", "", 24, nil); err != nil {
+		t.Fatal(err)
+	}
+	c.ResetCalls()
+
+	if _, err := c.Assign(ctx, "10.0.0.0/16", "", 24, nil); !errors.Is(err, haci.ErrQuotaExceeded) {
+		t.Fatalf("got %v, want ErrQuotaExceeded", err)
+	}
+	c.FailNext("Assign", 1, nil)
+	if _, err := c.Assign(ctx, "10.0.0.0/16", "", 25, nil); err == nil {
+		t.Fatal("injected failure was not returned")
+	}
+	for _, call := range c.Calls("Assign") {
+		if call.Err == nil {
+			t.Errorf("%s recorded without its error", call)
+		}
+	}
+
+	rt := &recordingT{}
+	if c.AssertAssigned(rt, "10.0.0.0/16", 24) || c.AssertAssigned(rt, "10.0.0.0/16", 25) || len(rt.errors) != 2 {
+		t.Errorf("AssertAssigned accepted failed assignments: %q", rt.errors)
+	}
+	rt = &recordingT{}
+	if !c.AssertAssignAttempted(rt, "10.0.0.0/16", 24) || !c.AssertAssignAttempted(rt, "10.0.0.0/16", 25) || len(rt.errors) != 0 {
+		t.Errorf("AssertAssignAttempted: %q", rt.errors)
+	}
+
+	c.SetQuota("10.0.0.0/16", haci.FakeQuota{})
+	if _, err := c.Assign(ctx, "10.0.0.0/16", "", 24, nil); err != nil {
+		t.Fatal(err)
+	}
+	c.AssertAssigned(t, "10.0.0.0/16", 24)
+}
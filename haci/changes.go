@@ -0,0 +1,89 @@
+package haci
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	neturl "net/url"
+	"strings"
+)
+
+// hashPrefix marks versions computed from the content of a listing.
+const hashPrefix = "sha256:"
+
+// ListIfChanged lists the subnets of supernet unless they are unchanged
+// since the listing that returned version. Pass an empty version for the
+// first call. It returns the networks, if changed, and the version to pass
+// to the next call.
+//
+// If HaCi sends an ETag, the listing is requested conditionally and not
+// downloaded again if unchanged. Otherwise the version is a hash of the
+// listing, which saves decoding it but not the download.
+func (c *WebClient) ListIfChanged(ctx context.Context, supernet, version string) (networks []Network, newVersion string, changed bool, err error) {
+	ctx, end := c.operation(ctx, "ListIfChanged", "supernet", supernet)
+	defer end(&err)
+
+	req := &Request{
+		Method:   "GET",
+		Endpoint: "getSubnets",
+		Params: neturl.Values{
+			"rootName": {c.Root},
+			"supernet": {supernet},
+		},
+		Header: http.Header{},
+	}
+	if version != "" && !strings.HasPrefix(version, hashPrefix) {
+		req.Header.Set("If-None-Match", version)
+	}
+	resp, err := c.do(ctx, req, nil)
+
+	if err != nil {
+		return nil, "", false, err
+	}
+
+	if resp.Status() == http.StatusNotModified {
+		return nil, version, false, nil
+	}
+
+	if resp.Status() != 200 {
+		return nil, "", false, c.responseError("list", resp)
+	}
+
+	newVersion = resp.Header.Get("ETag")
+	if newVersion == "" {
+		newVersion = contentVersion(resp.Body)
+	}
+	if newVersion == version {
+		return nil, version, false, nil
+	}
+	if err := json.Unmarshal(resp.Body, &networks); err != nil {
+		return nil, "", false, err
+	}
+	return networks, newVersion, true, nil
+}
+
+// ListIfChanged lists the subnets of supernet unless they are unchanged
+// since the listing that returned version. The version is a hash of the
+// listing.
+func (c *FakeClient) ListIfChanged(ctx context.Context, supernet, version string) ([]Network, string, bool, error) {
+	networks, err := c.List(ctx, supernet)
+	if err != nil {
+		return nil, "", false, err
+	}
+	data, err := json.Marshal(Networks(append([]Network{}, networks...)).SortByCIDR())
+	if err != nil {
+		return nil, "", false, err
+	}
+	newVersion := contentVersion(data)
+	if newVersion == version {
+		return nil, version, false, nil
+	}
+	return networks, newVersion, true, nil
+}
+
+func contentVersion(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hashPrefix + hex.EncodeToString(sum[:])
+}
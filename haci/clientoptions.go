@@ -0,0 +1,416 @@
+package haci
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	neturl "net/url"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// DefaultTimeout is the time a request may take unless changed with WithTimeout.
+	DefaultTimeout = 2 * time.Minute
+
+	// DefaultDialTimeout limits establishing the TCP connection.
+	DefaultDialTimeout = 30 * time.Second
+
+	// DefaultTLSHandshakeTimeout limits the TLS handshake.
+	DefaultTLSHandshakeTimeout = 10 * time.Second
+
+	// DefaultMaxIdleConnsPerHost is the number of idle connections kept
+	// open to the HaCi server for reuse.
+	DefaultMaxIdleConnsPerHost = 16
+
+	// DefaultIdleConnTimeout is how long idle connections are kept open.
+	DefaultIdleConnTimeout = 90 * time.Second
+
+	// DefaultConcurrency is the number of requests operations like
+	// ListRecursive send in parallel unless changed with WithConcurrency.
+	DefaultConcurrency = 4
+
+	// DefaultUserAgent is sent unless changed with WithUserAgent.
+	DefaultUserAgent = "go-haci-client"
+)
+
+// Option configures a WebClient created by NewWebClient.
+type Option func(*clientConfig) error
+
+type clientConfig struct {
+	username, password string
+	basicAuth          bool
+	session            bool
+	tokens             TokenSource
+	credentials        CredentialProvider
+	root               string
+	timeout            time.Duration
+	transport          http.RoundTripper
+	tls                *TLSOptions
+	retry              RetryPolicy
+	limiter            *rate.Limiter
+	breaker            *circuitBreaker
+	standby            []string
+	recheck            time.Duration
+	debugf             func(format string, args ...interface{})
+	debugBodies        bool
+	logger             Logger
+	getMutations       bool
+	readOnly           bool
+	dryRun             bool
+	audit              AuditSink
+	middleware         []Middleware
+	maxResponseSize    int64
+	maxDepth           int
+	resetRoot          string
+	concurrency        int
+	strategy           Strategy
+	serverPaging       bool
+	joinedTags         bool
+	overlapCheck       bool
+	detectCapabilities bool
+	metrics            *metrics
+	tracer             trace.Tracer
+	header             http.Header
+
+	// Settings for the default transport.
+	tuned                 bool
+	dialTimeout           time.Duration
+	tlsHandshakeTimeout   time.Duration
+	responseHeaderTimeout time.Duration
+	proxy                 func(*http.Request) (*neturl.URL, error)
+	maxIdleConnsPerHost   int
+	idleConnTimeout       time.Duration
+	disableKeepAlives     bool
+	noGzip                bool
+	http1                 bool
+	dialContext           func(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+func newClientConfig() *clientConfig {
+	return &clientConfig{
+		timeout:             DefaultTimeout,
+		dialTimeout:         DefaultDialTimeout,
+		tlsHandshakeTimeout: DefaultTLSHandshakeTimeout,
+		maxIdleConnsPerHost: DefaultMaxIdleConnsPerHost,
+		idleConnTimeout:     DefaultIdleConnTimeout,
+		logger:              nopLogger{},
+		concurrency:         DefaultConcurrency,
+		maxResponseSize:     DefaultMaxResponseSize,
+		maxDepth:            DefaultMaxDepth,
+		header:              http.Header{"User-Agent": {DefaultUserAgent}},
+		proxy:               http.ProxyFromEnvironment,
+	}
+}
+
+// WithBasicAuth authenticates every request with username and password.
+func WithBasicAuth(username, password string) Option {
+	return func(c *clientConfig) error {
+		c.username, c.password, c.basicAuth = username, password, true
+		c.session, c.tokens = false, nil
+		return nil
+	}
+}
+
+// WithRoot selects the HaCi root all operations work on.
+func WithRoot(root string) Option {
+	return func(c *clientConfig) error {
+		c.root = root
+		return nil
+	}
+}
+
+// WithUserAgent sets the User-Agent header sent with every request.
+func WithUserAgent(userAgent string) Option {
+	return func(c *clientConfig) error {
+		c.header.Set("User-Agent", userAgent)
+		return nil
+	}
+}
+
+// WithHeader adds a header sent with every request. It may be used several
+// times to add multiple values for the same header.
+func WithHeader(key, value string) Option {
+	return func(c *clientConfig) error {
+		c.header.Add(key, value)
+		return nil
+	}
+}
+
+// WithGETMutations sends Assign, Add, Update and Delete as GET requests instead of
+// POST. Use it for old HaCi versions whose RESTWrapper only accepts GET.
+// Descriptions and tags then become part of the URL.
+func WithGETMutations() Option {
+	return func(c *clientConfig) error {
+		c.getMutations = true
+		return nil
+	}
+}
+
+// WithReadOnly makes all calls that would change data in HaCi, like
+// Assign, Add, Update and Delete, fail with ErrReadOnly before any
+// request is sent.
+func WithReadOnly() Option {
+	return func(c *clientConfig) error {
+		c.readOnly = true
+		return nil
+	}
+}
+
+// WithAllowReset permits Reset to delete all networks in root. The client
+// must be configured for the same root, which guards against wiping a
+// production root by accident.
+func WithAllowReset(root string) Option {
+	return func(c *clientConfig) error {
+		c.resetRoot = root
+		return nil
+	}
+}
+
+// WithConcurrency limits the number of requests that operations working on
+// many networks, like ListRecursive, send in parallel.
+func WithConcurrency(n int) Option {
+	return func(c *clientConfig) error {
+		if n < 1 {
+			return fmt.Errorf("concurrency must be at least 1")
+		}
+		c.concurrency = n
+		return nil
+	}
+}
+
+// WithServerPaging passes Offset and Limit of ListWithOptions and
+// SearchWithOptions to HaCi as offset and limit parameters instead of
+// fetching the full result and paging it client-side. Only use it with
+// HaCi versions that support these parameters: a server that ignores them
+// is recognized, but then every page costs a request for the full result.
+func WithServerPaging() Option {
+	return func(c *clientConfig) error {
+		c.serverPaging = true
+		return nil
+	}
+}
+
+// WithJoinedTags sends tags as a single space-separated tags parameter
+// instead of one tags parameter per tag, for HaCi versions that only
+// understand the joined form. Tags containing whitespace are rejected then,
+// as the server would split them.
+func WithJoinedTags() Option {
+	return func(c *clientConfig) error {
+		c.joinedTags = true
+		return nil
+	}
+}
+
+// WithOverlapCheck makes Add look for existing networks that overlap the
+// new one first, and fail with an OverlapError if the network exists or
+// would contain existing networks. This costs a search of the whole root
+// per Add, but doesn't rely on how the HaCi version handles overlaps.
+func WithOverlapCheck() Option {
+	return func(c *clientConfig) error {
+		c.overlapCheck = true
+		return nil
+	}
+}
+
+// WithCapabilityDetection asks HaCi for its version before using features
+// that older versions lack. Exact search is then done by the client, and
+// mutations are sent as GET requests, if the server doesn't support them;
+// SearchTags fails with ErrUnsupported.
+func WithCapabilityDetection() Option {
+	return func(c *clientConfig) error {
+		c.detectCapabilities = true
+		return nil
+	}
+}
+
+// WithTimeout limits the time a single request may take, including dialing,
+// the TLS handshake and reading the response. Zero means no limit.
+// Use ContextWithTimeout to override it for individual calls.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *clientConfig) error {
+		if timeout < 0 {
+			return fmt.Errorf("timeout must not be negative")
+		}
+		c.timeout = timeout
+		return nil
+	}
+}
+
+// WithDialTimeout limits establishing the TCP connection. Zero means no limit.
+func WithDialTimeout(timeout time.Duration) Option {
+	return func(c *clientConfig) error {
+		c.dialTimeout, c.tuned = timeout, true
+		return nil
+	}
+}
+
+// WithTLSHandshakeTimeout limits the TLS handshake. Zero means no limit.
+func WithTLSHandshakeTimeout(timeout time.Duration) Option {
+	return func(c *clientConfig) error {
+		c.tlsHandshakeTimeout, c.tuned = timeout, true
+		return nil
+	}
+}
+
+// WithResponseHeaderTimeout limits the wait for the response headers after
+// the request was sent. Zero means no limit.
+func WithResponseHeaderTimeout(timeout time.Duration) Option {
+	return func(c *clientConfig) error {
+		c.responseHeaderTimeout, c.tuned = timeout, true
+		return nil
+	}
+}
+
+// WithMaxIdleConnsPerHost sets the number of idle connections kept open to
+// the HaCi server. Raise it if many requests are sent in parallel.
+func WithMaxIdleConnsPerHost(n int) Option {
+	return func(c *clientConfig) error {
+		c.maxIdleConnsPerHost, c.tuned = n, true
+		return nil
+	}
+}
+
+// WithIdleConnTimeout sets how long idle connections are kept open. Zero
+// means no limit.
+func WithIdleConnTimeout(timeout time.Duration) Option {
+	return func(c *clientConfig) error {
+		c.idleConnTimeout, c.tuned = timeout, true
+		return nil
+	}
+}
+
+// WithDisableKeepAlives opens a new connection for every request.
+func WithDisableKeepAlives() Option {
+	return func(c *clientConfig) error {
+		c.disableKeepAlives, c.tuned = true, true
+		return nil
+	}
+}
+
+// WithHTTP1 restricts the client to HTTP/1.1, for front proxies that
+// announce HTTP/2 but don't handle it well. By default HTTP/2 is used when
+// the server supports it, so parallel requests share one connection.
+func WithHTTP1() Option {
+	return func(c *clientConfig) error {
+		c.http1, c.tuned = true, true
+		return nil
+	}
+}
+
+// WithoutCompression stops asking HaCi for gzip compressed responses, for
+// servers or proxies that mishandle compression.
+func WithoutCompression() Option {
+	return func(c *clientConfig) error {
+		c.noGzip = true
+		return nil
+	}
+}
+
+// WithDialContext replaces the dialer that opens connections, e.g. to
+// connect through a tunnel. WithDialTimeout has no effect then.
+func WithDialContext(dial func(ctx context.Context, network, addr string) (net.Conn, error)) Option {
+	return func(c *clientConfig) error {
+		c.dialContext, c.tuned = dial, true
+		return nil
+	}
+}
+
+// WithProxy sends all requests through the proxy at proxyURL. Supported
+// schemes are http, https and socks5. Without this option, the proxy is
+// taken from the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.
+// An empty proxyURL disables proxies.
+func WithProxy(proxyURL string) Option {
+	return func(c *clientConfig) error {
+		c.tuned = true
+		if proxyURL == "" {
+			c.proxy = nil
+			return nil
+		}
+		u, err := neturl.Parse(proxyURL)
+		if err != nil {
+			return fmt.Errorf("invalid proxy URL: %s", err.Error())
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return fmt.Errorf("unsupported proxy scheme %q", u.Scheme)
+		}
+		c.proxy = http.ProxyURL(u)
+		return nil
+	}
+}
+
+// WithTransport replaces the HTTP transport. It cannot be combined with WithTLS
+// or the other transport settings, configure those on the transport instead.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(c *clientConfig) error {
+		c.transport = transport
+		return nil
+	}
+}
+
+// WithTLS configures certificate verification and client certificates.
+func WithTLS(tlsOptions TLSOptions) Option {
+	return func(c *clientConfig) error {
+		c.tls = &tlsOptions
+		return nil
+	}
+}
+
+func (c *clientConfig) httpClient() (*http.Client, error) {
+	transport := c.transport
+	if transport == nil {
+		tlsOptions := TLSOptions{}
+		if c.tls != nil {
+			tlsOptions = *c.tls
+		}
+		tlsConfig, err := tlsOptions.config()
+		if err != nil {
+			return nil, err
+		}
+		dial := c.dialContext
+		if dial == nil {
+			dial = (&net.Dialer{Timeout: c.dialTimeout, KeepAlive: 30 * time.Second}).DialContext
+		}
+		t := &http.Transport{
+			Proxy:                 c.proxy,
+			DialContext:           dial,
+			TLSClientConfig:       tlsConfig,
+			TLSHandshakeTimeout:   c.tlsHandshakeTimeout,
+			ResponseHeaderTimeout: c.responseHeaderTimeout,
+			MaxIdleConnsPerHost:   c.maxIdleConnsPerHost,
+			IdleConnTimeout:       c.idleConnTimeout,
+			DisableKeepAlives:     c.disableKeepAlives,
+			DisableCompression:    c.noGzip,
+			// HTTP/2 is used if the server offers it in the TLS handshake.
+			ForceAttemptHTTP2: !c.http1,
+		}
+		if c.http1 {
+			t.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+		}
+		transport = t
+	} else if c.tls != nil || c.tuned {
+		return nil, fmt.Errorf("transport settings cannot be combined with WithTransport")
+	}
+
+	if c.debugf != nil {
+		transport = &debugTransport{logf: c.debugf, bodies: c.debugBodies, next: transport}
+	}
+
+	// The overall timeout is enforced per call, see WebClient.get.
+	return &http.Client{Transport: transport}, nil
+}
+
+type timeoutKey struct{}
+
+// ContextWithTimeout returns a context that overrides the client timeout for
+// calls made with it. Zero disables the timeout. Unlike context.WithTimeout,
+// this can also extend the timeout, e.g. for listing very large supernets.
+func ContextWithTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, timeoutKey{}, timeout)
+}
@@ -0,0 +1,187 @@
+package haci
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is a client configuration file with named profiles, e.g.
+//
+//	default: prod
+//	profiles:
+//	  prod:
+//	    url: https://haci.example.com
+//	    root: infra
+//	    username: ipam
+//	    passwordEnv: HACI_PASSWORD
+//	    timeout: 30s
+//	    tls:
+//	      caFile: /etc/ssl/haci-ca.pem
+//	    retry:
+//	      maxAttempts: 5
+//	  lab:
+//	    url: https://haci-lab.example.com
+//	    tokenFile: /run/secrets/haci-token
+//
+// JSON files with the same structure are read as well.
+type Config struct {
+	// Default is the profile used if none is given.
+	Default  string                   `yaml:"default"`
+	Profiles map[string]ConfigProfile `yaml:"profiles"`
+}
+
+// ConfigProfile configures one WebClient. Secrets are not stored in the
+// file itself but referenced by the name of an environment variable or
+// the path of a file holding them.
+type ConfigProfile struct {
+	URL          string        `yaml:"url"`
+	Root         string        `yaml:"root"`
+	Username     string        `yaml:"username"`
+	PasswordEnv  string        `yaml:"passwordEnv"`
+	PasswordFile string        `yaml:"passwordFile"`
+	TokenEnv     string        `yaml:"tokenEnv"`
+	TokenFile    string        `yaml:"tokenFile"`
+	Timeout      time.Duration `yaml:"timeout"`
+	TLS          *struct {
+		CAFile             string `yaml:"caFile"`
+		CertFile           string `yaml:"certFile"`
+		KeyFile            string `yaml:"keyFile"`
+		InsecureSkipVerify bool   `yaml:"insecureSkipVerify"`
+	} `yaml:"tls"`
+	// Retry overrides fields of DefaultRetryPolicy.
+	Retry *struct {
+		MaxAttempts     int           `yaml:"maxAttempts"`
+		InitialBackoff  time.Duration `yaml:"initialBackoff"`
+		MaxBackoff      time.Duration `yaml:"maxBackoff"`
+		Multiplier      float64       `yaml:"multiplier"`
+		Jitter          float64       `yaml:"jitter"`
+		RetryableStatus []int         `yaml:"retryableStatus"`
+		RetryMutations  bool          `yaml:"retryMutations"`
+	} `yaml:"retry"`
+}
+
+// LoadConfig reads a configuration file.
+func LoadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config := &Config{}
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("reading %s: %s", path, err.Error())
+	}
+	return config, nil
+}
+
+// Client creates a client for a profile, or the default profile if profile
+// is empty. opts are applied after the settings of the profile.
+func (c *Config) Client(profile string, opts ...Option) (*WebClient, error) {
+	if profile == "" {
+		profile = c.Default
+	}
+	p, err := c.profile(profile)
+	if err != nil {
+		return nil, err
+	}
+	profileOpts, err := p.options()
+	if err != nil {
+		return nil, fmt.Errorf("profile %s: %s", profile, err.Error())
+	}
+	return NewWebClient(p.URL, append(profileOpts, opts...)...)
+}
+
+func (c *Config) profile(name string) (ConfigProfile, error) {
+	if name == "" && len(c.Profiles) == 1 {
+		for _, p := range c.Profiles {
+			return p, nil
+		}
+	}
+	p, ok := c.Profiles[name]
+	if !ok {
+		return ConfigProfile{}, fmt.Errorf("no profile %q in configuration", name)
+	}
+	if p.URL == "" {
+		return ConfigProfile{}, fmt.Errorf("profile %s has no url", name)
+	}
+	return p, nil
+}
+
+func (p ConfigProfile) options() ([]Option, error) {
+	opts := []Option{}
+	if p.Root != "" {
+		opts = append(opts, WithRoot(p.Root))
+	}
+	if p.Username != "" {
+		password, err := secret(p.PasswordEnv, p.PasswordFile)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithBasicAuth(p.Username, password))
+	}
+	if p.TokenEnv != "" || p.TokenFile != "" {
+		token, err := secret(p.TokenEnv, p.TokenFile)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithBearerToken(token))
+	}
+	if p.Timeout != 0 {
+		opts = append(opts, WithTimeout(p.Timeout))
+	}
+	if p.TLS != nil {
+		opts = append(opts, WithTLS(TLSOptions{
+			CAFile:             p.TLS.CAFile,
+			CertFile:           p.TLS.CertFile,
+			KeyFile:            p.TLS.KeyFile,
+			InsecureSkipVerify: p.TLS.InsecureSkipVerify,
+		}))
+	}
+	if r := p.Retry; r != nil {
+		policy := DefaultRetryPolicy
+		if r.MaxAttempts != 0 {
+			policy.MaxAttempts = r.MaxAttempts
+		}
+		if r.InitialBackoff != 0 {
+			policy.InitialBackoff = r.InitialBackoff
+		}
+		if r.MaxBackoff != 0 {
+			policy.MaxBackoff = r.MaxBackoff
+		}
+		if r.Multiplier != 0 {
+			policy.Multiplier = r.Multiplier
+		}
+		if r.Jitter != 0 {
+			policy.Jitter = r.Jitter
+		}
+		if r.RetryableStatus != nil {
+			policy.RetryableStatus = r.RetryableStatus
+		}
+		policy.RetryMutations = r.RetryMutations
+		opts = append(opts, WithRetry(policy))
+	}
+	return opts, nil
+}
+
+// secret reads a secret from the environment variable env or from file.
+func secret(env, file string) (string, error) {
+	switch {
+	case env != "":
+		value, ok := os.LookupEnv(env)
+		if !ok {
+			return "", fmt.Errorf("%s is not set", env)
+		}
+		return value, nil
+	case file != "":
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+	return "", nil
+}
@@ -0,0 +1,137 @@
+package haci
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// CredentialProvider supplies the username and password for basic auth
+// and for logging in with WithSession.
+type CredentialProvider interface {
+	Credentials(ctx context.Context) (username, password string, err error)
+}
+
+// StaticCredentials provides fixed credentials.
+type StaticCredentials struct {
+	Username string
+	Password string
+}
+
+func (s StaticCredentials) Credentials(ctx context.Context) (string, string, error) {
+	return s.Username, s.Password, nil
+}
+
+// FileCredentials reads the password from a file whenever credentials are
+// needed, so it can be rotated without restarting. Surrounding whitespace
+// is removed.
+type FileCredentials struct {
+	Username     string
+	PasswordFile string
+}
+
+func (f FileCredentials) Credentials(ctx context.Context) (string, string, error) {
+	data, err := ioutil.ReadFile(f.PasswordFile)
+	if err != nil {
+		return "", "", err
+	}
+	return f.Username, strings.TrimSpace(string(data)), nil
+}
+
+// ExecCredentials runs a command that prints the username on the first
+// line of its output and the password on the second, e.g. a script
+// reading them from Vault.
+type ExecCredentials struct {
+	Command []string
+}
+
+func (e ExecCredentials) Credentials(ctx context.Context) (string, string, error) {
+	if len(e.Command) == 0 {
+		return "", "", fmt.Errorf("no credentials command")
+	}
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, e.Command[0], e.Command[1:]...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", "", fmt.Errorf("credentials command failed: %s: %s", err.Error(), strings.TrimSpace(stderr.String()))
+	}
+	lines := strings.SplitN(strings.TrimRight(string(out), "\r\n"), "\n", 2)
+	if len(lines) != 2 {
+		return "", "", fmt.Errorf("credentials command did not print username and password")
+	}
+	return strings.TrimSpace(lines[0]), strings.TrimRight(lines[1], "\r"), nil
+}
+
+// WithCredentialProvider takes the username and password from p instead
+// of a fixed pair. With basic auth, p is consulted for the first request
+// and again whenever HaCi rejects the credentials; with WithSession, it is
+// consulted for every login.
+func WithCredentialProvider(p CredentialProvider) Option {
+	return func(c *clientConfig) error {
+		c.credentials = p
+		if !c.session {
+			c.basicAuth, c.tokens = true, nil
+		}
+		return nil
+	}
+}
+
+// credentialCache holds the credentials last returned by a provider.
+type credentialCache struct {
+	provider CredentialProvider
+
+	mu                 sync.Mutex
+	username, password string
+	valid              bool
+}
+
+// get returns the cached credentials, asking the provider if there are
+// none or fresh is set.
+func (cc *credentialCache) get(ctx context.Context, fresh bool) (string, string, error) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if cc.valid && !fresh {
+		return cc.username, cc.password, nil
+	}
+	username, password, err := cc.provider.Credentials(ctx)
+	if err != nil {
+		return "", "", fmt.Errorf("getting credentials: %w", err)
+	}
+	cc.username, cc.password, cc.valid = username, password, true
+	return username, password, nil
+}
+
+// userPassword returns the credentials to authenticate with.
+func (c *WebClient) userPassword(ctx context.Context, fresh bool) (string, string, error) {
+	if c.credentials == nil {
+		return c.username, c.password, nil
+	}
+	return c.credentials.get(ctx, fresh)
+}
+
+// authorize sets the Authorization header of req for basic auth or a
+// bearer token. fresh asks the credential provider again.
+func (c *WebClient) authorize(ctx context.Context, req *Request, fresh bool) error {
+	switch {
+	case c.basicAuth:
+		username, password, err := c.userPassword(ctx, fresh)
+		if err != nil {
+			return err
+		}
+		auth := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+		req.Header.Set("Authorization", "Basic "+auth)
+	case c.tokens != nil:
+		token, err := c.tokens.Token(ctx)
+		if err != nil {
+			return fmt.Errorf("getting bearer token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package haci
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"time"
+)
+
+// WithDebugLogging logs every HTTP request with its method, URL, status and
+// latency using logf, which has the signature of log.Printf. If bodies is
+// true, request bodies and the first 4 KiB of response bodies are logged as
+// well. Credentials are never logged.
+func WithDebugLogging(logf func(format string, args ...interface{}), bodies bool) Option {
+	return func(c *clientConfig) error {
+		c.debugf, c.debugBodies = logf, bodies
+		return nil
+	}
+}
+
+// debugBodyLimit is the number of bytes of a response body that are logged.
+const debugBodyLimit = 4096
+
+// debugTransport logs requests and responses.
+type debugTransport struct {
+	logf   func(format string, args ...interface{})
+	bodies bool
+	next   http.RoundTripper
+}
+
+func (t *debugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	url := sanitizeURL(req.URL)
+
+	if t.bodies && req.Body != nil {
+		body, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		t.logf("haci: %s %s body: %s", req.Method, url, sanitizeBody(body))
+	}
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	latency := time.Since(start)
+
+	if err != nil {
+		t.logf("haci: %s %s failed after %s: %s", req.Method, url, latency, err.Error())
+		return nil, err
+	}
+	t.logf("haci: %s %s %d in %s", req.Method, url, resp.StatusCode, latency)
+
+	if t.bodies {
+		// Only the start of the body is read here, the rest is still
+		// streamed and subject to the limits of the client.
+		prefix, err := ioutil.ReadAll(io.LimitReader(resp.Body, debugBodyLimit+1))
+		if err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(prefix), resp.Body), resp.Body}
+		if len(prefix) > debugBodyLimit {
+			t.logf("haci: %s %s response: %s... (truncated)", req.Method, url, prefix[:debugBodyLimit])
+		} else {
+			t.logf("haci: %s %s response: %s", req.Method, url, prefix)
+		}
+	}
+
+	return resp, nil
+}
+
+// secretParam reports whether a query or form parameter holds a credential.
+func secretParam(name string) bool {
+	name = strings.ToLower(name)
+	for _, s := range []string{"pass", "token", "secret", "auth", "key"} {
+		if strings.Contains(name, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func redactValues(values neturl.Values) neturl.Values {
+	redacted := neturl.Values{}
+	for k, v := range values {
+		if secretParam(k) {
+			redacted[k] = []string{"REDACTED"}
+		} else {
+			redacted[k] = v
+		}
+	}
+	return redacted
+}
+
+// sanitizeURL returns the URL without user info and with credentials in
+// the query redacted.
+func sanitizeURL(u *neturl.URL) string {
+	clean := *u
+	clean.User = nil
+	clean.RawQuery = redactValues(u.Query()).Encode()
+	return clean.String()
+}
+
+// sanitizeBody redacts credentials in form encoded bodies.
+func sanitizeBody(body []byte) string {
+	if bytes.HasPrefix(bytes.TrimSpace(body), []byte("{")) {
+		return string(body)
+	}
+	values, err := neturl.ParseQuery(string(body))
+	if err != nil || len(values) == 0 {
+		return string(body)
+	}
+	return redactValues(values).Encode()
+}
@@ -0,0 +1,53 @@
+package haci_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Nexinto/go-haci-client/haci"
+)
+
+func TestDebugLoggingLimits(t *testing.T) {
+	ctx := context.Background()
+	body := "[" + strings.Repeat(`{"network":"10.0.0.0/24","description":"x"},`, 100000) + `{"network":"10.0.1.0/24"}]`
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, body)
+	}))
+	defer s.Close()
+
+	logged := 0
+	logf := func(format string, args ...interface{}) {
+		logged += len(fmt.Sprintf(format, args...))
+	}
+	c, err := haci.NewWebClient(s.URL, haci.WithRoot("test"), haci.WithoutCompression(),
+		haci.WithDebugLogging(logf, true), haci.WithMaxResponseSize(100000))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.List(ctx, "10.0.0.0/16"); !errors.Is(err, haci.ErrLimitExceeded) {
+		t.Errorf("got %v, want ErrLimitExceeded", err)
+	}
+	if logged > 10000 {
+		t.Errorf("logged %d bytes of a %d bytes response", logged, len(body))
+	}
+
+	// Below the limit, the response is passed on in full.
+	c, err = haci.NewWebClient(s.URL, haci.WithRoot("test"), haci.WithoutCompression(), haci.WithDebugLogging(logf, true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	networks, err := c.List(ctx, "10.0.0.0/16")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(networks) != 100001 || networks[100000].Network != "10.0.1.0/24" {
+		t.Errorf("got %d networks", len(networks))
+	}
+}
@@ -0,0 +1,77 @@
+package haci
+
+import (
+	"context"
+	"errors"
+	neturl "net/url"
+	"sort"
+)
+
+// DeleteOptions controls how DeleteWithOptions removes a network. The zero
+// value deletes like Delete.
+type DeleteOptions struct {
+	// NoLock sends networkLock=0, so HaCi doesn't lock the network while
+	// deleting it.
+	NoLock bool
+	// Force sends force=1, which makes HaCi remove networks it otherwise
+	// refuses to delete, e.g. ones that are still referenced.
+	Force bool
+}
+
+func (o DeleteOptions) values(root, network string) *neturl.Values {
+	values := neturl.Values{
+		"rootName":    {root},
+		"network":     {network},
+		"networkLock": {"1"},
+	}
+	if o.NoLock {
+		values.Set("networkLock", "0")
+	}
+	if o.Force {
+		values.Set("force", "1")
+	}
+	return &values
+}
+
+// DeleteRecursive deletes network and all networks below it, the most
+// specific ones first. It returns the deleted networks in that order. With
+// dryRun nothing is deleted, and the networks that would be deleted are
+// returned.
+func (c *WebClient) DeleteRecursive(ctx context.Context, network string, dryRun bool) (networks []Network, err error) {
+	ctx, end := c.operation(ctx, "DeleteRecursive", "network", network)
+	defer end(&err)
+
+	return deleteRecursive(ctx, c, network, dryRun)
+}
+
+func (c *FakeClient) DeleteRecursive(ctx context.Context, network string, dryRun bool) ([]Network, error) {
+	return deleteRecursive(ctx, c, network, dryRun)
+}
+
+func deleteRecursive(ctx context.Context, c Client, network string, dryRun bool) ([]Network, error) {
+	top, err := c.Get(ctx, network)
+	if err != nil {
+		return nil, err
+	}
+	descendants, err := c.ListRecursive(ctx, network)
+	if err != nil {
+		return nil, err
+	}
+
+	networks := append(descendants, top)
+	sort.SliceStable(networks, func(i, j int) bool {
+		return prefixLen(networks[i].Network) > prefixLen(networks[j].Network)
+	})
+	if dryRun {
+		return networks, nil
+	}
+
+	deleted := []Network{}
+	for _, n := range networks {
+		if err := c.Delete(ctx, n.Network); err != nil && !errors.Is(err, ErrNotFound) {
+			return deleted, err
+		}
+		deleted = append(deleted, n)
+	}
+	return deleted, nil
+}
@@ -0,0 +1,150 @@
+package haci
+
+import (
+	"context"
+	"net"
+	neturl "net/url"
+	"strings"
+	"sync"
+)
+
+// WithDryRun makes all requests that change data, including those sent
+// with Do, only log and record the change they would make. Assign picks
+// the network it would assign with PeekFree. The recorded changes are
+// returned by PlannedChanges.
+func WithDryRun() Option {
+	return func(c *clientConfig) error {
+		c.dryRun = true
+		return nil
+	}
+}
+
+// PlannedChange is a change a client in dry-run mode did not make.
+type PlannedChange struct {
+	// Op is "assign", "add", "update", "delete", "create root" or
+	// "delete root", or the endpoint for other requests sent with Do.
+	Op          string
+	Root        string
+	Network     string
+	Description string
+	Tags        []string
+}
+
+// changeLog records planned changes.
+type changeLog struct {
+	mu      sync.Mutex
+	changes []PlannedChange
+}
+
+// plannedOps names the changes made by the mutating endpoints.
+var plannedOps = map[string]string{
+	"assignFreeSubnet": "assign",
+	"addNet":           "add",
+	"editNet":          "update",
+	"delNet":           "delete",
+	"addRoot":          "create root",
+	"delRoot":          "delete root",
+}
+
+// planRequest records the change a mutating request would make.
+func (c *WebClient) planRequest(endpoint string, params neturl.Values) {
+	op, ok := plannedOps[endpoint]
+	if !ok {
+		op = endpoint
+	}
+	var tags []string
+	for _, v := range params["tags"] {
+		if c.joinedTags {
+			tags = append(tags, strings.Fields(v)...)
+		} else if v != "" {
+			tags = append(tags, v)
+		}
+	}
+	c.plan(PlannedChange{
+		Op:          op,
+		Root:        params.Get("rootName"),
+		Network:     params.Get("network"),
+		Description: params.Get("description"),
+		Tags:        tags,
+	})
+}
+
+// plan logs and records a change.
+func (c *WebClient) plan(change PlannedChange) {
+	c.planned.mu.Lock()
+	defer c.planned.mu.Unlock()
+	c.record(change)
+}
+
+// record logs and records a change. c.planned.mu must be held.
+func (c *WebClient) record(change PlannedChange) {
+	c.log.Info("HaCi dry run", "op", change.Op, "root", change.Root, "network", change.Network, "description", change.Description, "tags", change.Tags)
+	c.planned.changes = append(c.planned.changes, change)
+}
+
+// planAssign records the assignment Assign would make in dry-run mode. It
+// picks the network like PeekFree, but treats the networks planned earlier
+// as used, so a batch of planned assignments gets distinct networks.
+func (c *WebClient) planAssign(ctx context.Context, supernet, description string, cidr int, tags []string) (Network, error) {
+	_, super, err := net.ParseCIDR(supernet)
+	if err != nil {
+		return Network{}, err
+	}
+
+	// Hold the lock until the assignment is recorded, so concurrent
+	// assignments see each other.
+	c.planned.mu.Lock()
+	defer c.planned.mu.Unlock()
+
+	used, err := usedNetworks(ctx, c.List, supernet)
+	if err != nil {
+		return Network{}, err
+	}
+	used = append(used, c.plannedIn(super)...)
+
+	free := []string{}
+	for _, block := range freeBlocks(super, used, cidr) {
+		free = append(free, block.String())
+	}
+	if len(free) == 0 {
+		return Network{}, newError(ErrNoFreeSubnet, "no free /%d in %s", cidr, supernet)
+	}
+	network, err := c.strategy.pick(free, cidr)
+	if err != nil {
+		return Network{}, err
+	}
+
+	c.record(PlannedChange{Op: "assign", Root: c.Root, Network: network, Description: description, Tags: tags})
+	return Network{Network: network, Description: description, Tags: tags}, nil
+}
+
+// plannedIn returns the networks planned to be assigned or added in the
+// root of the client that are inside super. c.planned.mu must be held.
+func (c *WebClient) plannedIn(super *net.IPNet) []*net.IPNet {
+	superOnes, _ := super.Mask.Size()
+	in := []*net.IPNet{}
+	for _, change := range c.planned.changes {
+		if change.Root != c.Root || change.Op != "assign" && change.Op != "add" {
+			continue
+		}
+		_, n, err := net.ParseCIDR(change.Network)
+		if err != nil {
+			continue
+		}
+		if ones, _ := n.Mask.Size(); ones > superOnes && super.Contains(n.IP) {
+			in = append(in, n)
+		}
+	}
+	return in
+}
+
+// PlannedChanges returns the changes recorded in dry-run mode, in the
+// order they were planned.
+func (c *WebClient) PlannedChanges() []PlannedChange {
+	if c.planned == nil {
+		return nil
+	}
+	c.planned.mu.Lock()
+	defer c.planned.mu.Unlock()
+	return append([]PlannedChange{}, c.planned.changes...)
+}
@@ -0,0 +1,121 @@
+package haci_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Nexinto/go-haci-client/haci"
+	"github.com/Nexinto/go-haci-client/haci/hacitest"
+)
+
+// mutatingEndpoints are the RESTWrapper endpoints that change data.
+var mutatingEndpoints = map[string]bool{
+	"assignFreeSubnet": true,
+	"addNet":           true,
+	"delNet":           true,
+	"editNet":          true,
+	"addRoot":          true,
+	"delRoot":          true,
+}
+
+func TestDryRunSendsNoMutations(t *testing.T) {
+	ctx := context.Background()
+	s := hacitest.NewServer("test", "other", "victim")
+	defer s.Close()
+	fake := s.Fake("test")
+	for _, n := range []string{"10.0.0.0/16", "10.0.1.0/24", "10.0.2.0/24"} {
+		if err := fake.Add(ctx, n, "existing", []string{"keep"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c, err := haci.NewWebClient(s.URL, haci.WithRoot("test"), haci.WithDryRun())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calls := []struct {
+		name string
+		call func() error
+	}{
+		{"Assign", func() error { _, err := c.Assign(ctx, "10.0.0.0/16", "new", 24, nil); return err }},
+		{"AssignMany", func() error { _, err := c.AssignMany(ctx, "10.0.0.0/16", "new", 24, 2, nil); return err }},
+		{"Add", func() error { return c.Add(ctx, "10.0.3.0/24", "new", []string{"t"}) }},
+		{"Update", func() error { return c.Update(ctx, "10.0.1.0/24", "changed", nil) }},
+		{"Delete", func() error { return c.Delete(ctx, "10.0.1.0/24") }},
+		{"DeleteWithOptions", func() error {
+			return c.DeleteWithOptions(ctx, "10.0.2.0/24", haci.DeleteOptions{})
+		}},
+		{"AddTags", func() error { return c.AddTags(ctx, "10.0.1.0/24", []string{"more"}) }},
+		{"RemoveTags", func() error { return c.RemoveTags(ctx, "10.0.1.0/24", []string{"keep"}) }},
+		{"EnsureNetwork", func() error { return c.EnsureNetwork(ctx, "10.0.4.0/24", "new", nil) }},
+		{"BulkAdd", func() error {
+			return c.BulkAdd(ctx, []haci.NetworkSpec{{Network: "10.0.5.0/24"}, {Network: "10.0.6.0/24"}}, 2)
+		}},
+		{"BulkDelete", func() error { return c.BulkDelete(ctx, []string{"10.0.1.0/24", "10.0.2.0/24"}, 2) }},
+		{"Move", func() error { return c.Move(ctx, "10.0.1.0/24", "other", false) }},
+		{"CreateRoot", func() error { return c.CreateRoot(ctx, "new", "", false) }},
+		{"DeleteRoot", func() error { return c.DeleteRoot(ctx, "victim") }},
+		{"Do", func() error {
+			return c.Do(ctx, "delNet", map[string][]string{"network": {"10.0.1.0/24"}}, nil)
+		}},
+	}
+	for _, tc := range calls {
+		before := len(c.PlannedChanges())
+		if err := tc.call(); err != nil {
+			t.Errorf("%s: %s", tc.name, err)
+			continue
+		}
+		if len(c.PlannedChanges()) == before {
+			t.Errorf("%s planned no change", tc.name)
+		}
+	}
+
+	for _, r := range s.Requests() {
+		if mutatingEndpoints[r.Endpoint] {
+			t.Errorf("server received %s %s in dry-run mode", r.Method, r.Endpoint)
+		}
+	}
+	if s.Fake("victim") == nil {
+		t.Errorf("root victim was deleted")
+	}
+	if _, err := fake.Get(ctx, "10.0.1.0/24"); err != nil {
+		t.Errorf("10.0.1.0/24 was deleted: %s", err)
+	}
+}
+
+func TestDryRunAssignsDistinctNetworks(t *testing.T) {
+	ctx := context.Background()
+	s := hacitest.NewServer("test")
+	defer s.Close()
+	fake := s.Fake("test")
+	for _, n := range []string{"10.0.0.0/16", "10.0.0.0/24"} {
+		if err := fake.Add(ctx, n, "existing", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c, err := haci.NewWebClient(s.URL, haci.WithRoot("test"), haci.WithDryRun())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Add(ctx, "10.0.1.0/24", "planned", nil); err != nil {
+		t.Fatal(err)
+	}
+	networks, err := c.AssignMany(ctx, "10.0.0.0/16", "new", 24, 3, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := c.Assign(ctx, "10.0.0.0/16", "new", 23, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	networks = append(networks, n)
+
+	want := []string{"10.0.2.0/24", "10.0.3.0/24", "10.0.4.0/24", "10.0.6.0/23"}
+	for i, n := range networks {
+		if n.Network != want[i] {
+			t.Errorf("planned assignment %d is %s, want %s", i, n.Network, want[i])
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package haci
+
+import (
+	"context"
+	"errors"
+)
+
+// EnsureNetwork makes sure network exists with the given description and
+// tags. It adds the network if it is missing, updates it if description
+// or tags differ, and does nothing otherwise. The order of tags is ignored.
+func (c *WebClient) EnsureNetwork(ctx context.Context, network, description string, tags []string) (err error) {
+	ctx, end := c.operation(ctx, "EnsureNetwork", "network", network)
+	defer end(&err)
+
+	return ensureNetwork(ctx, c, network, description, tags)
+}
+
+func (c *FakeClient) EnsureNetwork(ctx context.Context, network, description string, tags []string) error {
+	return ensureNetwork(ctx, c, network, description, tags)
+}
+
+func ensureNetwork(ctx context.Context, c Client, network, description string, tags []string) error {
+	existing, err := c.Get(ctx, network)
+	if errors.Is(err, ErrNotFound) {
+		return c.Add(ctx, network, description, tags)
+	}
+	if err != nil {
+		return err
+	}
+
+	if existing.Equal(Network{Network: network, Description: description, Tags: tags}) {
+		return nil
+	}
+	return c.Update(ctx, network, description, tags)
+}
@@ -0,0 +1,65 @@
+package haci
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// NewWebClientFromEnv creates a client configured by environment variables:
+//
+//	HACI_URL                   URL of the HaCi server (required)
+//	HACI_ROOT                  root to work on
+//	HACI_USERNAME, HACI_PASSWORD
+//	                           credentials for basic auth
+//	HACI_TOKEN                 bearer token, instead of username and password
+//	HACI_TIMEOUT               request timeout, e.g. "30s"
+//	HACI_CA_FILE               PEM bundle of CAs to trust
+//	HACI_CERT_FILE, HACI_KEY_FILE
+//	                           client certificate and key
+//	HACI_INSECURE_SKIP_VERIFY  "true" disables certificate verification
+//
+// opts are applied after the settings from the environment.
+func NewWebClientFromEnv(opts ...Option) (*WebClient, error) {
+	url := os.Getenv("HACI_URL")
+	if url == "" {
+		return nil, fmt.Errorf("HACI_URL is not set")
+	}
+
+	envOpts := []Option{}
+	if root := os.Getenv("HACI_ROOT"); root != "" {
+		envOpts = append(envOpts, WithRoot(root))
+	}
+	if username := os.Getenv("HACI_USERNAME"); username != "" {
+		envOpts = append(envOpts, WithBasicAuth(username, os.Getenv("HACI_PASSWORD")))
+	}
+	if token := os.Getenv("HACI_TOKEN"); token != "" {
+		envOpts = append(envOpts, WithBearerToken(token))
+	}
+	if s := os.Getenv("HACI_TIMEOUT"); s != "" {
+		timeout, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid HACI_TIMEOUT: %s", err.Error())
+		}
+		envOpts = append(envOpts, WithTimeout(timeout))
+	}
+
+	tlsOptions := TLSOptions{
+		CAFile:   os.Getenv("HACI_CA_FILE"),
+		CertFile: os.Getenv("HACI_CERT_FILE"),
+		KeyFile:  os.Getenv("HACI_KEY_FILE"),
+	}
+	if s := os.Getenv("HACI_INSECURE_SKIP_VERIFY"); s != "" {
+		insecure, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid HACI_INSECURE_SKIP_VERIFY: %s", err.Error())
+		}
+		tlsOptions.InsecureSkipVerify = insecure
+	}
+	if tlsOptions.CAFile != "" || tlsOptions.CertFile != "" || tlsOptions.KeyFile != "" || tlsOptions.InsecureSkipVerify {
+		envOpts = append(envOpts, WithTLS(tlsOptions))
+	}
+
+	return NewWebClient(url, append(envOpts, opts...)...)
+}
@@ -0,0 +1,198 @@
+package haci
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Errors returned by Client implementations, to be tested with errors.Is.
+var (
+	// ErrNotFound means the requested network does not exist.
+	ErrNotFound = errors.New("network not found")
+
+	// ErrAlreadyExists means a network to be added already exists.
+	ErrAlreadyExists = errors.New("network already exists")
+
+	// ErrNoFreeSubnet means a supernet has no free subnet of the requested size.
+	ErrNoFreeSubnet = errors.New("no free subnet")
+
+	// ErrUnreachable means the HaCi server could not be reached.
+	ErrUnreachable = errors.New("HaCi unreachable")
+
+	// ErrUnauthorized means HaCi rejected the credentials.
+	ErrUnauthorized = errors.New("unauthorized")
+
+	// ErrRootNotFound means the root of the client does not exist.
+	ErrRootNotFound = errors.New("root not found")
+
+	// ErrReadOnly means a client created with WithReadOnly was asked to
+	// change data.
+	ErrReadOnly = errors.New("client is read-only")
+
+	// ErrLimitExceeded means a response exceeded the size or nesting
+	// limits of the client.
+	ErrLimitExceeded = errors.New("response limit exceeded")
+
+	// ErrUnsupported means the HaCi server does not support a feature.
+	ErrUnsupported = errors.New("unsupported by HaCi server")
+
+	// ErrInvalidNetwork means a network is not in CIDR notation or has
+	// host bits set.
+	ErrInvalidNetwork = errors.New("invalid network")
+
+	// ErrInvalidPrefixLen means a prefix length is out of range for the
+	// address family or does not fit into the supernet.
+	ErrInvalidPrefixLen = errors.New("invalid prefix length")
+
+	// ErrOverlap means a network overlaps existing networks.
+	ErrOverlap = errors.New("network overlaps existing networks")
+
+	// ErrInvalidTag means a tag cannot be stored in HaCi as given.
+	ErrInvalidTag = errors.New("invalid tag")
+
+	// ErrQuotaExceeded means a supernet has reached its quota, see
+	// FakeClient.SetQuota.
+	ErrQuotaExceeded = errors.New("quota exceeded")
+)
+
+// kindError is an error with its own message that matches one of the
+// error values above.
+type kindError struct {
+	msg  string
+	kind error
+}
+
+func (e *kindError) Error() string { return e.msg }
+func (e *kindError) Unwrap() error { return e.kind }
+
+func newError(kind error, format string, args ...interface{}) error {
+	return &kindError{msg: fmt.Sprintf(format, args...), kind: kind}
+}
+
+// APIError is returned by WebClient when HaCi answers a request with an error.
+// It matches ErrNotFound, ErrAlreadyExists, ErrNoFreeSubnet or ErrUnauthorized
+// if the response indicates one of these conditions. FakeClient returns it
+// in the same situations.
+type APIError struct {
+	// Op is the operation that failed, e.g. "lookup" or "assignment".
+	Op string
+
+	// StatusCode is the HTTP status of the response.
+	StatusCode int
+
+	// Message is the error message from HaCi, or the raw response body if
+	// it could not be parsed.
+	Message string
+
+	// Code is the error code reported by HaCi, if any.
+	Code string
+
+	// Endpoint is the RESTWrapper endpoint that was called, e.g. "getNetworkDetails".
+	Endpoint string
+
+	// Root is the HaCi root the request was made for.
+	Root string
+
+	kind error
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s failed: %s", e.Op, e.Message)
+}
+
+func (e *APIError) Unwrap() error { return e.kind }
+
+// responseError returns the error for a request that HaCi answered with
+// a status other than 200.
+func (c *WebClient) responseError(op string, resp *Response) error {
+	e := &APIError{
+		Op:         op,
+		StatusCode: resp.Status(),
+		Message:    resp.RawText(),
+		Endpoint:   resp.Endpoint,
+		Root:       c.Root,
+	}
+
+	// Newer HaCi versions send a JSON object describing the error.
+	var body struct {
+		Error     string      `json:"error"`
+		Message   string      `json:"message"`
+		ErrorCode interface{} `json:"errorCode"`
+		Code      interface{} `json:"code"`
+	}
+	parsed := json.Unmarshal([]byte(e.Message), &body) == nil
+	if parsed {
+		if body.Error != "" {
+			e.Message = body.Error
+		} else if body.Message != "" {
+			e.Message = body.Message
+		}
+		for _, code := range []interface{}{body.ErrorCode, body.Code} {
+			if code != nil {
+				e.Code = fmt.Sprint(code)
+				break
+			}
+		}
+	}
+
+	if parsed && (body.Error != "" || body.Message != "") || fromHaCi(resp) {
+		e.kind = classify(e.StatusCode, e.Message)
+	} else {
+		e.kind = classify(e.StatusCode, "")
+	}
+	return e
+}
+
+// fromHaCi reports whether the plain body of an error response is a
+// message of HaCi rather than the error page of a proxy or web server in
+// front of it, e.g. "404 Not Found" for a wrong URL.
+func fromHaCi(resp *Response) bool {
+	body := strings.TrimSpace(resp.RawText())
+	if strings.Contains(strings.ToLower(resp.Header.Get("Content-Type")), "html") || strings.HasPrefix(body, "<") {
+		return false
+	}
+	text := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(body, strconv.Itoa(resp.Status()))))
+	return text != "" && text != strings.ToLower(http.StatusText(resp.Status())) && text != "page not found"
+}
+
+// fakeError returns the error WebClient returns if HaCi answers a request
+// to endpoint with status and message, for FakeClient.
+func fakeError(op, endpoint string, status int, format string, args ...interface{}) error {
+	message := fmt.Sprintf(format, args...)
+	return &APIError{
+		Op:         op,
+		StatusCode: status,
+		Message:    message,
+		Endpoint:   endpoint,
+		kind:       classify(status, message),
+	}
+}
+
+// classify maps a HaCi error response to one of the error values. HaCi
+// mostly reports errors as text, so the message is inspected as well. A
+// 404 alone does not mean ErrNotFound, HaCi answers unknown endpoints with
+// it and so do proxies for unknown paths; text is empty if the response is
+// not from HaCi.
+func classify(status int, text string) error {
+	text = strings.ToLower(text)
+	switch {
+	case status == http.StatusUnauthorized, status == http.StatusForbidden:
+		return ErrUnauthorized
+	case strings.Contains(text, "not found"),
+		strings.Contains(text, "does not exist"),
+		strings.Contains(text, "doesn't exist"):
+		return ErrNotFound
+	case status == http.StatusConflict,
+		strings.Contains(text, "already exist"):
+		return ErrAlreadyExists
+	case strings.Contains(text, "no free"),
+		strings.Contains(text, "no more free"),
+		strings.Contains(text, "not enough free"):
+		return ErrNoFreeSubnet
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package haci
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"time"
+)
+
+// ExportFormat is the file format of Export.
+type ExportFormat string
+
+const (
+	// ExportJSON writes a JSON object with the root, the time of the
+	// export and an array of networks with all their fields, e.g.
+	//
+	//	{"root":{"name":"infra","description":"","ipv6":false},
+	//	 "exported":"2024-05-01T02:00:00Z",
+	//	 "networks":[
+	//	{"createDate":"...","network":"10.0.0.0/16",...}
+	//	]}
+	ExportJSON ExportFormat = "json"
+
+	// ExportCSV writes a header and one row per network with the columns
+	// of exportColumns. The tags are a JSON array, e.g. ["a","b,c"], as
+	// tags may contain commas.
+	ExportCSV ExportFormat = "csv"
+)
+
+// exportColumns are the fields of a CSV export, named like in JSON.
+var exportColumns = []string{"network", "description", "tags", "ID", "state",
+	"createDate", "createFrom", "modifyDate", "modifyFrom", "defSubnetSize"}
+
+// ExportHeader is the header of an export in ExportJSON format.
+type ExportHeader struct {
+	Root     Root      `json:"root"`
+	Exported time.Time `json:"exported"`
+}
+
+// Export writes all networks of root with their tags and metadata to w.
+// They are fetched with a single search and written while the response is
+// decoded, so even huge roots are exported without holding them in memory
+// and the export is consistent with one state of HaCi.
+func (c *WebClient) Export(ctx context.Context, root string, w io.Writer, format ExportFormat) (err error) {
+	ctx, end := c.operation(ctx, "Export", "root", root)
+	defer end(&err)
+
+	return export(ctx, c.WithRoot(root), root, w, format)
+}
+
+// Export writes all networks of root, those of the fake returned by
+// WithRoot, or those of the fake itself if root is empty.
+func (c *FakeClient) Export(ctx context.Context, root string, w io.Writer, format ExportFormat) error {
+	if root == "" {
+		return export(ctx, c, root, w, format)
+	}
+	return export(ctx, c.WithRoot(root), root, w, format)
+}
+
+// eachNetwork calls fn for every network of the root, decoding them one at
+// a time from the response of a search for all networks.
+func (c *WebClient) eachNetwork(ctx context.Context, fn func(Network) error) error {
+	req := &Request{
+		Method:   "GET",
+		Endpoint: "search",
+		Params: neturl.Values{
+			"rootName":    {c.Root},
+			"search":      {""},
+			"withDetails": {"1"},
+		},
+		Header: http.Header{},
+	}
+	resp, err := c.do(withStream(ctx, func(r io.Reader) error {
+		return decodeEach(r, fn)
+	}), req, nil)
+
+	if err != nil {
+		return err
+	}
+
+	if resp.Status() != 200 {
+		return c.responseError("search", resp)
+	}
+
+	return nil
+}
+
+func (c *FakeClient) eachNetwork(ctx context.Context, fn func(Network) error) error {
+	networks, err := c.Search(ctx, "", false)
+	if err != nil {
+		return err
+	}
+	for _, n := range networks {
+		if err := fn(n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// networkSource is a client that reads all networks of its root with a
+// single request.
+type networkSource interface {
+	eachNetwork(ctx context.Context, fn func(Network) error) error
+}
+
+// exporter is a client Export can read all networks of a root from.
+type exporter interface {
+	networkSource
+	ListRoots(ctx context.Context) ([]Root, error)
+}
+
+func export(ctx context.Context, c exporter, root string, w io.Writer, format ExportFormat) error {
+	var write func(Network) error
+	var finish func() error
+
+	switch format {
+	case ExportJSON:
+		header := ExportHeader{Root: Root{Name: root}, Exported: time.Now().UTC()}
+		roots, err := c.ListRoots(ctx)
+		if err != nil {
+			return err
+		}
+		for _, r := range roots {
+			if r.Name == root {
+				header.Root = r
+			}
+		}
+		start, err := json.Marshal(header)
+		if err != nil {
+			return err
+		}
+		// The networks array is appended to the header object.
+		if _, err := fmt.Fprintf(w, "%s,\"networks\":[", start[:len(start)-1]); err != nil {
+			return err
+		}
+
+		sep := "\n"
+		write = func(n Network) error {
+			data, err := json.Marshal(n)
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintf(w, "%s%s", sep, data); err != nil {
+				return err
+			}
+			sep = ",\n"
+			return nil
+		}
+		finish = func() error {
+			_, err := io.WriteString(w, "\n]}\n")
+			return err
+		}
+
+	case ExportCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(exportColumns); err != nil {
+			return err
+		}
+		write = func(n Network) error {
+			row := []string{}
+			for _, column := range exportColumns {
+				if column == "tags" {
+					tags, err := json.Marshal(append([]string{}, n.Tags...))
+					if err != nil {
+						return err
+					}
+					row = append(row, string(tags))
+					continue
+				}
+				value, _ := n.field(column)
+				row = append(row, value)
+			}
+			return cw.Write(row)
+		}
+		finish = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+
+	default:
+		return fmt.Errorf("unknown export format %q", format)
+	}
+
+	if err := c.eachNetwork(ctx, write); err != nil {
+		return err
+	}
+	return finish()
+}
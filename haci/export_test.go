@@ -0,0 +1,124 @@
+package haci_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/Nexinto/go-haci-client/haci"
+	"github.com/Nexinto/go-haci-client/haci/hacitest"
+)
+
+func TestExportSingleSearch(t *testing.T) {
+	ctx := context.Background()
+	s := hacitest.NewServer("test")
+	defer s.Close()
+	// More networks than fit into one page of a paged export.
+	state := haci.FakeState{
+		Added:     []haci.Network{{Network: "10.0.0.0/16", Description: "parent"}},
+		Supernets: map[string]haci.FakeSupernetState{"10.0.0.0/16": {}},
+	}
+	want := []string{"10.0.0.0/16"}
+	children := []haci.Network{}
+	for i := 0; i < 2500; i++ {
+		n := fmt.Sprintf("10.0.%d.%d/30", i/64, i%64*4)
+		children = append(children, haci.Network{Network: n, Description: "child", Tags: []string{"t"}})
+		want = append(want, n)
+	}
+	state.Supernets["10.0.0.0/16"] = haci.FakeSupernetState{Networks: children}
+	if err := s.Fake("test").Restore(state); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, opts := range map[string][]haci.Option{
+		"default":      nil,
+		"serverPaging": {haci.WithServerPaging()},
+	} {
+		c, err := haci.NewWebClient(s.URL, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		before := len(s.Requests())
+
+		var buf bytes.Buffer
+		if err := c.Export(ctx, "test", &buf, haci.ExportJSON); err != nil {
+			t.Fatalf("%s: Export: %s", name, err)
+		}
+
+		searches := 0
+		for _, r := range s.Requests()[before:] {
+			if r.Endpoint == "search" {
+				searches++
+			}
+		}
+		if searches != 1 {
+			t.Errorf("%s: export sent %d searches, want 1", name, searches)
+		}
+
+		var export struct {
+			haci.ExportHeader
+			Networks []haci.Network `json:"networks"`
+		}
+		if err := json.Unmarshal(buf.Bytes(), &export); err != nil {
+			t.Fatalf("%s: invalid export: %s", name, err)
+		}
+		if export.Root.Name != "test" {
+			t.Errorf("%s: exported root %q, want test", name, export.Root.Name)
+		}
+		if len(export.Networks) != len(want) {
+			t.Fatalf("%s: exported %d networks, want %d", name, len(export.Networks), len(want))
+		}
+		for i, n := range export.Networks {
+			if n.Network != want[i] {
+				t.Errorf("%s: network %d is %s, want %s", name, i, n.Network, want[i])
+				break
+			}
+		}
+	}
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	networks := []haci.Network{
+		{Network: "10.0.0.0/24", Description: "comma, \"quotes\"", Tags: []string{"a,b", "c"}},
+		{Network: "10.0.1.0/24", Description: "no tags"},
+		{Network: "10.0.2.0/24", Description: "quoted tag", Tags: []string{`say "hi"`, "[x]"}},
+	}
+
+	for _, format := range []haci.ExportFormat{haci.ExportJSON, haci.ExportCSV} {
+		src := haci.NewFakeClient()
+		for _, n := range networks {
+			if err := src.WithRoot("test").Add(ctx, n.Network, n.Description, n.Tags); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		var buf bytes.Buffer
+		if err := src.Export(ctx, "test", &buf, format); err != nil {
+			t.Fatalf("%s: Export: %s", format, err)
+		}
+		dst := haci.NewFakeClient()
+		result, err := dst.Import(ctx, &buf, haci.ImportOptions{Root: "test", Format: format})
+		if err != nil {
+			t.Fatalf("%s: Import: %s", format, err)
+		}
+		if result.Added != len(networks) {
+			t.Errorf("%s: imported %d networks, want %d", format, result.Added, len(networks))
+		}
+
+		for _, want := range networks {
+			got, err := dst.WithRoot("test").Get(ctx, want.Network)
+			if err != nil {
+				t.Fatalf("%s: Get %s: %s", format, want.Network, err)
+			}
+			if got.Description != want.Description {
+				t.Errorf("%s: %s has description %q, want %q", format, want.Network, got.Description, want.Description)
+			}
+			if fmt.Sprint(got.Tags) != fmt.Sprint(want.Tags) || len(got.Tags) != len(want.Tags) {
+				t.Errorf("%s: %s has tags %q, want %q", format, want.Network, got.Tags, want.Tags)
+			}
+		}
+	}
+}
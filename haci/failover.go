@@ -0,0 +1,70 @@
+package haci
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// WithFailover adds standby HaCi servers that are used, in order, when the
+// server at the client URL cannot be reached. While a standby is active,
+// the primary is tried again every recheck interval and takes over as
+// soon as it answers.
+func WithFailover(recheck time.Duration, standby ...string) Option {
+	return func(c *clientConfig) error {
+		for _, url := range standby {
+			c.standby = append(c.standby, strings.TrimRight(url, "/"))
+		}
+		c.recheck = recheck
+		return nil
+	}
+}
+
+// endpoints tracks which of several base URLs is currently in use.
+type endpoints struct {
+	urls    []string
+	recheck time.Duration
+
+	mu         sync.Mutex
+	active     int
+	switchedAt time.Time
+}
+
+// order returns the indexes of the URLs in the order they should be tried:
+// the active one first, or the primary if it is due for a recheck.
+func (e *endpoints) order() []int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	start := e.active
+	if start != 0 && time.Since(e.switchedAt) >= e.recheck {
+		start = 0
+	}
+
+	order := make([]int, 0, len(e.urls))
+	for i := range e.urls {
+		order = append(order, (start+i)%len(e.urls))
+	}
+	return order
+}
+
+// reached records that the URL with index i answered.
+func (e *endpoints) reached(i int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if i != e.active {
+		e.active, e.switchedAt = i, time.Now()
+	}
+}
+
+// unreachable records that the URL with index i could not be reached.
+func (e *endpoints) unreachable(i int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if i == 0 && e.active != 0 {
+		// Failed recheck, wait another interval before trying the primary.
+		e.switchedAt = time.Now()
+	}
+}
@@ -0,0 +1,112 @@
+package haci
+
+import (
+	"context"
+	"time"
+)
+
+// fault is a failure or delay injected into a FakeClient method.
+type fault struct {
+	failures int
+	err      error
+	delay    time.Duration
+}
+
+// FailNext makes the next n calls of the method op, e.g. "Assign", return
+// err. With n < 0, all calls fail until ClearFaults. If err is nil, the
+// calls fail with an error matching ErrUnreachable, like a server that
+// cannot be reached. An empty op matches all methods.
+//
+// Faults are injected into the methods that access the networks directly;
+// methods built on them, like AssignMany or EnsureNetwork, fail when the
+// methods they use do.
+func (c *FakeClient) FailNext(op string, n int, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	f := c.fault(op)
+	f.failures, f.err = n, err
+}
+
+// Delay makes calls of the method op take at least d. A call returns the
+// error of its context if the context ends earlier. An empty op matches
+// all methods.
+func (c *FakeClient) Delay(op string, d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.fault(op).delay = d
+}
+
+// ClearFaults removes all failures and delays set with FailNext and Delay.
+func (c *FakeClient) ClearFaults() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.faults = nil
+}
+
+func (c *FakeClient) fault(op string) *fault {
+	if c.faults == nil {
+		c.faults = map[string]*fault{}
+	}
+	if c.faults[op] == nil {
+		c.faults[op] = &fault{}
+	}
+	return c.faults[op]
+}
+
+// inject records a call of op with args and applies the delays and
+// failures set for op. It must be called before the client is locked.
+func (c *FakeClient) inject(ctx context.Context, op string, args ...interface{}) error {
+	_, err := c.injectCall(ctx, op, args...)
+	return err
+}
+
+// injectCall is inject for methods that record the error they return
+// with returned.
+func (c *FakeClient) injectCall(ctx context.Context, op string, args ...interface{}) (*Call, error) {
+	c.mu.Lock()
+	call := &Call{Op: op, Args: args}
+	c.calls = append(c.calls, call)
+	var delay time.Duration
+	var err error
+	for _, key := range []string{"", op} {
+		f := c.faults[key]
+		if f == nil {
+			continue
+		}
+		delay += f.delay
+		if err == nil && f.failures != 0 {
+			if f.failures > 0 {
+				f.failures--
+			}
+			err = f.err
+			if err == nil {
+				err = newError(ErrUnreachable, "injected failure of %s", op)
+			}
+		}
+	}
+	call.Err = err
+	c.mu.Unlock()
+
+	if delay > 0 {
+		t := time.NewTimer(delay)
+		defer t.Stop()
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+			c.returned(call, &err)
+			return call, err
+		case <-t.C:
+		}
+	}
+	return call, err
+}
+
+// returned records the error a call returned.
+func (c *FakeClient) returned(call *Call, err *error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	call.Err = *err
+}
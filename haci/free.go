@@ -0,0 +1,206 @@
+package haci
+
+import (
+	"context"
+	"math/big"
+	"net"
+
+	ccidr "github.com/apparentlymart/go-cidr/cidr"
+)
+
+// FreeSubnets returns the unassigned parts of supernet that can hold a
+// subnet with prefix length cidr, as the largest possible aligned networks
+// in address order. Split them to get subnets of exactly that size.
+func (c *WebClient) FreeSubnets(ctx context.Context, supernet string, cidr int) (free []string, err error) {
+	ctx, end := c.operation(ctx, "FreeSubnets", "supernet", supernet)
+	defer end(&err)
+
+	return freeSubnets(ctx, c.List, supernet, cidr)
+}
+
+func (c *FakeClient) FreeSubnets(ctx context.Context, supernet string, cidr int) ([]string, error) {
+	if err := c.inject(ctx, "FreeSubnets", supernet, cidr); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return freeSubnets(ctx, c.children, supernet, cidr)
+}
+
+func freeSubnets(ctx context.Context, list func(context.Context, string) ([]Network, error), supernet string, cidr int) ([]string, error) {
+	if err := validateSubnet(supernet, cidr); err != nil {
+		return nil, err
+	}
+	_, super, _ := net.ParseCIDR(supernet)
+
+	used, err := usedNetworks(ctx, list, supernet)
+	if err != nil {
+		return nil, err
+	}
+
+	free := []string{}
+	for _, block := range freeBlocks(super, used, cidr) {
+		free = append(free, block.String())
+	}
+	return free, nil
+}
+
+// usedNetworks returns the direct subnets of supernet.
+func usedNetworks(ctx context.Context, list func(context.Context, string) ([]Network, error), supernet string) ([]*net.IPNet, error) {
+	children, err := list(ctx, supernet)
+	if err != nil {
+		return nil, err
+	}
+
+	used := []*net.IPNet{}
+	for _, n := range children {
+		_, u, err := net.ParseCIDR(n.Network)
+		if err != nil {
+			return nil, err
+		}
+		used = append(used, u)
+	}
+	return used, nil
+}
+
+// freeBlocks splits block until the parts either overlap none of the used
+// networks or are smaller than prefix length cidr, and returns the free ones.
+func freeBlocks(block *net.IPNet, used []*net.IPNet, cidr int) []*net.IPNet {
+	ones, _ := block.Mask.Size()
+
+	overlapping := []*net.IPNet{}
+	for _, u := range used {
+		if uones, _ := u.Mask.Size(); uones <= ones && u.Contains(block.IP) {
+			// Everything in block is in use.
+			return nil
+		}
+		if block.Contains(u.IP) {
+			overlapping = append(overlapping, u)
+		}
+	}
+	if len(overlapping) == 0 {
+		return []*net.IPNet{block}
+	}
+
+	if ones >= cidr {
+		return nil
+	}
+
+	lower, _ := ccidr.Subnet(block, 1, 0)
+	upper, _ := ccidr.Subnet(block, 1, 1)
+	return append(freeBlocks(lower, overlapping, cidr), freeBlocks(upper, overlapping, cidr)...)
+}
+
+// PeekFree returns the network Assign would hand out for the same
+// arguments, without assigning it. With the Random strategy, it is just one
+// of the possible choices. A concurrent assignment may take it first.
+func (c *WebClient) PeekFree(ctx context.Context, supernet string, cidr int) (network string, err error) {
+	ctx, end := c.operation(ctx, "PeekFree", "supernet", supernet)
+	defer end(&err)
+
+	free, err := c.FreeSubnets(ctx, supernet, cidr)
+	if err != nil {
+		return "", err
+	}
+	if len(free) == 0 {
+		return "", newError(ErrNoFreeSubnet, "no free /%d in %s", cidr, supernet)
+	}
+	return c.strategy.pick(free, cidr)
+}
+
+func (c *FakeClient) PeekFree(ctx context.Context, supernet string, cidr int) (string, error) {
+	if err := c.inject(ctx, "PeekFree", supernet, cidr); err != nil {
+		return "", err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.peekFree(ctx, supernet, cidr)
+}
+
+// peekFree picks a subnet from the free blocks of supernet like PeekFree
+// of WebClient does, so freed space is handed out again.
+func (c *FakeClient) peekFree(ctx context.Context, supernet string, cidr int) (string, error) {
+	if err := validateSubnet(supernet, cidr); err != nil {
+		return "", err
+	}
+	_, super, _ := net.ParseCIDR(supernet)
+
+	used, err := usedNetworks(ctx, c.children, supernet)
+	if err != nil {
+		return "", err
+	}
+	if _, bits := super.Mask.Size(); cidr == bits && !c.UseFirst {
+		// Don't hand out the network address as a host.
+		used = append(used, &net.IPNet{IP: super.IP, Mask: net.CIDRMask(bits, bits)})
+	}
+
+	free := []string{}
+	for _, block := range freeBlocks(super, used, cidr) {
+		free = append(free, block.String())
+	}
+	if len(free) == 0 {
+		return "", newError(ErrNoFreeSubnet, "no free /%d in %s", cidr, supernet)
+	}
+	return c.Strategy.pick(free, cidr)
+}
+
+// Utilization describes how much of a supernet is assigned.
+type Utilization struct {
+	Total   *big.Int
+	Used    *big.Int
+	Free    *big.Int
+	Percent float64
+}
+
+// Utilization counts the addresses of supernet that are covered by its
+// subnets. Nested subnets are included in their parents and not counted
+// twice.
+func (c *WebClient) Utilization(ctx context.Context, supernet string) (u Utilization, err error) {
+	ctx, end := c.operation(ctx, "Utilization", "supernet", supernet)
+	defer end(&err)
+
+	return utilization(ctx, c, supernet)
+}
+
+func (c *FakeClient) Utilization(ctx context.Context, supernet string) (Utilization, error) {
+	return utilization(ctx, c, supernet)
+}
+
+func utilization(ctx context.Context, c Client, supernet string) (Utilization, error) {
+	_, super, err := net.ParseCIDR(supernet)
+	if err != nil {
+		return Utilization{}, err
+	}
+
+	// The direct subnets cover all their descendants.
+	children, err := c.List(ctx, supernet)
+	if err != nil {
+		return Utilization{}, err
+	}
+
+	u := Utilization{Total: addressCount(super), Used: big.NewInt(0)}
+	for _, n := range children {
+		_, child, err := net.ParseCIDR(n.Network)
+		if err != nil {
+			return Utilization{}, err
+		}
+		u.Used.Add(u.Used, addressCount(child))
+	}
+	u.Free = new(big.Int).Sub(u.Total, u.Used)
+
+	percent := new(big.Float).Quo(new(big.Float).SetInt(u.Used), new(big.Float).SetInt(u.Total))
+	u.Percent, _ = percent.Mul(percent, big.NewFloat(100)).Float64()
+
+	return u, nil
+}
+
+// addressCount returns the number of addresses in n, which may exceed
+// 64 bits for IPv6.
+func addressCount(n *net.IPNet) *big.Int {
+	ones, bits := n.Mask.Size()
+	return new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+}
@@ -1,15 +1,24 @@
 package haci
 
 import (
-	"crypto/tls"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
+	"net/http/cookiejar"
 	neturl "net/url"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
-	ccidr "github.com/apparentlymart/go-cidr/cidr"
-	"gopkg.in/jmcvetta/napping.v3"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/singleflight"
+	"golang.org/x/time/rate"
 )
 
 type Network struct {
@@ -18,6 +27,15 @@ type Network struct {
 	Description string   `json:"description"`
 	Network     string   `json:"network"`
 	Tags        []string `json:"tags"`
+
+	// ID is the internal ID of the network in HaCi.
+	ID json.Number `json:"ID,omitempty"`
+	// State is the name of the network state, e.g. "ALLOCATED PA".
+	State      string `json:"state,omitempty"`
+	ModifyDate string `json:"modifyDate,omitempty"`
+	ModifyFrom string `json:"modifyFrom,omitempty"`
+	// DefSubnetSize is the default subnet prefix length, empty if none is set.
+	DefSubnetSize json.Number `json:"defSubnetSize,omitempty"`
 }
 
 func (n Network) IP() (string, error) {
@@ -30,52 +48,276 @@ func (n Network) IP() (string, error) {
 	return ip.String(), nil
 }
 
+// dateFormats are the formats HaCi versions use for createDate.
+var dateFormats = []string{
+	"2006-01-02 15:04:05",
+	"02.01.2006 15:04:05",
+	time.RFC3339,
+	"Mon Jan _2 15:04:05 2006",
+}
+
+// CreatedAt parses the creation date of the network. Dates without a time
+// zone are taken to be in the local time zone; numeric dates are seconds
+// since the epoch.
+func (n Network) CreatedAt() (time.Time, error) {
+	return parseDate(n.CreateDate)
+}
+
+// ModifiedAt parses the date the network was last modified, like CreatedAt.
+func (n Network) ModifiedAt() (time.Time, error) {
+	return parseDate(n.ModifyDate)
+}
+
+func parseDate(s string) (time.Time, error) {
+	if secs, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(secs, 0), nil
+	}
+	for _, f := range dateFormats {
+		if t, err := time.ParseInLocation(f, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("cannot parse date %q", s)
+}
+
+// Client is the interface to HaCi shared by WebClient and FakeClient. All
+// implementations are safe for concurrent use by multiple goroutines.
 type Client interface {
-	Get(network string) (Network, error)
-	List(supernet string) ([]Network, error)
-	Assign(supernet string, description string, cidr int, tags []string) (Network, error)
-	Delete(network string) error
-	Add(network, description string, tags []string) error
-	Search(description string, exact bool) ([]Network, error)
-	Reset() error
+	Get(ctx context.Context, network string) (Network, error)
+	GetMany(ctx context.Context, networks []string, concurrency int) (map[string]Network, error)
+	List(ctx context.Context, supernet string) ([]Network, error)
+	ListIter(ctx context.Context, supernet string, fn func(Network) error) error
+	ListIfChanged(ctx context.Context, supernet, version string) ([]Network, string, bool, error)
+	ListRecursive(ctx context.Context, supernet string) ([]Network, error)
+	Tree(ctx context.Context, supernet string) (*TreeNode, error)
+	GetParent(ctx context.Context, network string) (Network, error)
+	GetChildren(ctx context.Context, network string) ([]Network, error)
+	GetByIP(ctx context.Context, ip string) (Network, error)
+	FreeSubnets(ctx context.Context, supernet string, cidr int) ([]string, error)
+	Utilization(ctx context.Context, supernet string) (Utilization, error)
+	PeekFree(ctx context.Context, supernet string, cidr int) (string, error)
+	Assign(ctx context.Context, supernet string, description string, cidr int, tags []string) (Network, error)
+	AssignMany(ctx context.Context, supernet, description string, cidr, count int, tags []string) ([]Network, error)
+	AssignBlock(ctx context.Context, supernet, description string, cidr, count int, tags []string) ([]Network, error)
+	AssignOrGet(ctx context.Context, supernet, description string, cidr int, tags []string) (Network, error)
+	AssignFromAny(ctx context.Context, supernets []string, order CandidateOrder, description string, cidr int, tags []string) (Network, error)
+	Delete(ctx context.Context, network string) error
+	DeleteWithOptions(ctx context.Context, network string, opts DeleteOptions) error
+	DeleteRecursive(ctx context.Context, network string, dryRun bool) ([]Network, error)
+	Move(ctx context.Context, network, targetRoot string, recursive bool) error
+	BulkAdd(ctx context.Context, specs []NetworkSpec, concurrency int) error
+	BulkDelete(ctx context.Context, networks []string, concurrency int) error
+	Add(ctx context.Context, network, description string, tags []string) error
+	Update(ctx context.Context, network, description string, tags []string) error
+	EnsureNetwork(ctx context.Context, network, description string, tags []string) error
+	AddTags(ctx context.Context, network string, tags []string) error
+	RemoveTags(ctx context.Context, network string, tags []string) error
+	Search(ctx context.Context, description string, exact bool) ([]Network, error)
+	SearchTags(ctx context.Context, tags []string, matchAll bool) ([]Network, error)
+	Overlaps(ctx context.Context, cidr string) ([]Network, error)
+	ListWithOptions(ctx context.Context, supernet string, opts Options) (Page, error)
+	SearchWithOptions(ctx context.Context, description string, exact bool, opts Options) (Page, error)
+	ListRoots(ctx context.Context) ([]Root, error)
+	CreateRoot(ctx context.Context, name, description string, ipv6 bool) error
+	DeleteRoot(ctx context.Context, name string) error
+	Export(ctx context.Context, root string, w io.Writer, format ExportFormat) error
+	Import(ctx context.Context, r io.Reader, opts ImportOptions) (ImportResult, error)
+	Ping(ctx context.Context) error
+	Version(ctx context.Context) (string, error)
+	Supports(ctx context.Context, capability Capability) (bool, error)
+	Reset(ctx context.Context) error
 	String() string
 }
 
 type WebClient struct {
-	napping napping.Session
-	URL     string
-	Root    string
+	client       *http.Client
+	header       http.Header
+	basicAuth    bool
+	username     string
+	password     string
+	tokens       TokenSource
+	credentials  *credentialCache
+	getMutations bool
+	readOnly     bool
+	noGzip       bool
+	maxSize      int64
+	maxDepth     int
+	audit        AuditSink
+	middleware   []Middleware
+	resetRoot    string
+	timeout      time.Duration
+	retry        RetryPolicy
+	limiter      *rate.Limiter
+	breaker      *circuitBreaker
+	endpoints    *endpoints
+	log          Logger
+	metrics      *metrics
+	tracer       trace.Tracer
+	concurrency  int
+	strategy     Strategy
+	serverPaging bool
+	joinedTags   bool
+	overlapCheck bool
+	detectCaps   bool
+
+	// tagMu serializes read-modify-write cycles of tags. It is shared
+	// with clients created by WithRoot.
+	tagMu *sync.Mutex
+
+	// flight coalesces concurrent identical GET requests, also across
+	// WithRoot clients, see coalesce.
+	flight *singleflight.Group
+
+	// planned records the changes of WithDryRun, nil otherwise. It is
+	// shared with WithRoot clients.
+	planned *changeLog
+
+	// session is the login of WithSession, nil with other authentication.
+	session *session
+
+	// server caches the version of HaCi, also shared with WithRoot clients.
+	server *serverInfo
+
+	URL  string
+	Root string
 }
 
-// A very simple and limited client for unit tests.
+// A very simple and limited client for unit tests. It is safe for concurrent
+// use; the exported fields must not be accessed while the client is in use.
+// Like HaCi, it returns networks sorted by address and then prefix length.
 type FakeClient struct {
+	mu     sync.Mutex
+	faults map[string]*fault
+	quotas map[string]FakeQuota
+	calls  []*Call
+	lastID int64
+
+	// roots holds the fakes of other roots, see WithRoot.
+	roots map[string]*FakeClient
+
 	UseFirst  bool
+	Strategy  Strategy
 	Supernets map[string]*FakeSupernet
-	Added     map[string]Network
+	Roots     map[string]Root
+
+	// Clock returns the time networks are created and modified at,
+	// time.Now if nil.
+	Clock func() time.Time
+
+	// User is the user networks are created and modified by, "fake" if
+	// empty.
+	User string
+
+	// OverlapCheck makes Add reject networks that contain existing
+	// networks, like WithOverlapCheck does for WebClient. Otherwise, the
+	// existing networks are nested below the new one.
+	OverlapCheck bool
+
+	// Added holds the networks added outside of any supernet. Networks
+	// added inside one are kept with its subnets.
+	Added map[string]Network
 }
 
 type FakeSupernet struct {
 	Networks map[string]Network
 	Network  net.IPNet
-	Last     net.IP
 }
 
-func NewWebClient(url, username, password, root string) (haci *WebClient, err error) {
-	transport := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+// Create a new HaCi client for the server at url. Without options, requests
+// are unauthenticated, use the default root and verify the server certificate.
+//
+// NewWebClient used to take the credentials and the root as arguments.
+// Calls of the old form
+//
+//	NewWebClient(url, username, password, root)
+//
+// become
+//
+//	NewWebClient(url, WithBasicAuth(username, password), WithRoot(root))
+//
+// or, with the old arguments, NewWebClientWithAuth.
+func NewWebClient(url string, opts ...Option) (*WebClient, error) {
+	config := newClientConfig()
+	for _, opt := range opts {
+		if err := opt(config); err != nil {
+			return nil, err
+		}
 	}
-	client := &http.Client{Transport: transport}
 
-	haci = &WebClient{
-		napping: napping.Session{
-			Log:      false,
-			Client:   client,
-			Userinfo: neturl.UserPassword(username, password),
-		},
-		URL:  strings.TrimRight(url, "/"),
-		Root: root,
+	client, err := config.httpClient()
+	if err != nil {
+		return nil, err
 	}
-	return
+
+	haci := &WebClient{
+		client:       client,
+		header:       config.header,
+		basicAuth:    config.basicAuth,
+		username:     config.username,
+		password:     config.password,
+		tokens:       config.tokens,
+		getMutations: config.getMutations,
+		readOnly:     config.readOnly,
+		noGzip:       config.noGzip,
+		maxSize:      config.maxResponseSize,
+		maxDepth:     config.maxDepth,
+		audit:        config.audit,
+		middleware:   config.middleware,
+		resetRoot:    config.resetRoot,
+		timeout:      config.timeout,
+		retry:        config.retry,
+		log:          config.logger,
+		metrics:      config.metrics,
+		tracer:       config.tracer,
+		concurrency:  config.concurrency,
+		strategy:     config.strategy,
+		serverPaging: config.serverPaging,
+		joinedTags:   config.joinedTags,
+		overlapCheck: config.overlapCheck,
+		detectCaps:   config.detectCapabilities,
+		server:       &serverInfo{},
+		limiter:      config.limiter,
+		breaker:      config.breaker,
+		tagMu:        &sync.Mutex{},
+		flight:       &singleflight.Group{},
+		URL:          strings.TrimRight(url, "/"),
+		Root:         config.root,
+	}
+	if config.dryRun {
+		haci.planned = &changeLog{}
+	}
+	if config.credentials != nil {
+		haci.credentials = &credentialCache{provider: config.credentials}
+	}
+	if config.session {
+		// The cookie jar is shared with WithRoot clients like the transport.
+		jar, err := cookiejar.New(nil)
+		if err != nil {
+			return nil, err
+		}
+		haci.client.Jar = jar
+		haci.session = &session{}
+	}
+	haci.endpoints = &endpoints{
+		urls:    append([]string{haci.URL}, config.standby...),
+		recheck: config.recheck,
+	}
+	return haci, nil
+}
+
+// Create a new HaCi client using basic auth, with the arguments
+// NewWebClient used to take.
+//
+// Deprecated: use NewWebClient(url, WithBasicAuth(username, password), WithRoot(root)).
+func NewWebClientWithAuth(url, username, password, root string) (*WebClient, error) {
+	return NewWebClient(url, WithBasicAuth(username, password), WithRoot(root))
+}
+
+// Create a new HaCi client using basic auth and custom TLS settings.
+//
+// Deprecated: use NewWebClient(url, WithBasicAuth(username, password), WithRoot(root), WithTLS(tlsOptions)).
+func NewWebClientTLS(url, username, password, root string, tlsOptions TLSOptions) (*WebClient, error) {
+	return NewWebClient(url, WithBasicAuth(username, password), WithRoot(root), WithTLS(tlsOptions))
 }
 
 // Create a new HaCi fake client.
@@ -88,77 +330,152 @@ func NewFakeClientUsesFirst() *FakeClient {
 	return &FakeClient{Supernets: map[string]*FakeSupernet{}, Added: map[string]Network{}, UseFirst: true}
 }
 
-func (c *WebClient) Get(network string) (network1 Network, err error) {
-	resp, err := c.napping.Get(c.URL+"/RESTWrapper/getNetworkDetails",
+func (c *WebClient) Get(ctx context.Context, network string) (network1 Network, err error) {
+	ctx, end := c.operation(ctx, "Get", "network", network)
+	defer end(&err)
+
+	resp, err := c.get(ctx, "getNetworkDetails",
 		&neturl.Values{
 			"rootName": {c.Root},
 			"network":  {network},
 		},
-		&network1,
-		nil)
+		&network1)
 
 	if err != nil {
 		return Network{}, err
 	}
 
 	if resp.Status() != 200 {
-		return Network{}, fmt.Errorf("lookup failed: %s", resp.RawText())
+		return Network{}, c.responseError("lookup", resp)
 	}
 
 	return
 }
 
-func (c *WebClient) List(supernet string) (networks []Network, err error) {
-	resp, err := c.napping.Get(c.URL+"/RESTWrapper/getSubnets",
-		&neturl.Values{
-			"rootName": {c.Root},
-			"supernet": {supernet},
-		},
-		&networks,
-		nil)
+func (c *WebClient) List(ctx context.Context, supernet string) (networks []Network, err error) {
+	ctx, end := c.operation(ctx, "List", "supernet", supernet)
+	defer end(&err)
+
+	return c.list(ctx, supernet, nil)
+}
+
+// list fetches the subnets of supernet, sending the extra parameters as well.
+func (c *WebClient) list(ctx context.Context, supernet string, extra neturl.Values) (networks []Network, err error) {
+	values := neturl.Values{
+		"rootName": {c.Root},
+		"supernet": {supernet},
+	}
+	for k, v := range extra {
+		values[k] = v
+	}
+	resp, networks, err := c.getNetworks(ctx, "getSubnets", &values)
 
 	if err != nil {
 		return []Network{}, err
 	}
 
 	if resp.Status() != 200 {
-		return []Network{}, fmt.Errorf("list failed: %s", resp.RawText())
+		return []Network{}, c.responseError("list", resp)
 	}
 
 	return
 }
 
-func (c *WebClient) Assign(supernet, description string, cidr int, tags []string) (network1 Network, err error) {
-	resp, err := c.napping.Get(c.URL+"/RESTWrapper/assignFreeSubnet",
+func (c *WebClient) Assign(ctx context.Context, supernet, description string, cidr int, tags []string) (network1 Network, err error) {
+	ctx, end := c.operation(ctx, "Assign", "supernet", supernet)
+	defer end(&err)
+
+	if err := validateSubnet(supernet, cidr); err != nil {
+		return Network{}, err
+	}
+
+	if c.planned != nil {
+		return c.planAssign(ctx, supernet, description, cidr, tags)
+	}
+
+	if c.strategy != FirstFit {
+		return c.assignWithStrategy(ctx, supernet, description, cidr, tags)
+	}
+
+	tagValues, err := c.tagValues(tags)
+	if err != nil {
+		return Network{}, err
+	}
+
+	resp, err := c.post(ctx, "assignFreeSubnet",
 		&neturl.Values{
 			"rootName":    {c.Root},
 			"supernet":    {supernet},
 			"description": {description},
 			"cidr":        {fmt.Sprintf("%d", cidr)},
-			"tags":        {strings.Join(tags, " ")},
+			"tags":        tagValues,
 		},
-		&network1,
-		nil)
+		&network1)
 
 	if err != nil {
 		return Network{}, err
 	}
 
 	if resp.Status() != 200 {
-		return Network{}, fmt.Errorf("assignment failed: %s", resp.RawText())
+		return Network{}, c.responseError("assignment", resp)
 	}
 
 	return
 }
 
-func (c *WebClient) Delete(network string) (err error) {
-	resp, err := c.napping.Get(c.URL+"/RESTWrapper/delNet",
+func (c *WebClient) Delete(ctx context.Context, network string) (err error) {
+	return c.DeleteWithOptions(ctx, network, DeleteOptions{})
+}
+
+// DeleteWithOptions deletes a network like Delete, with control over the
+// parameters of delNet.
+func (c *WebClient) DeleteWithOptions(ctx context.Context, network string, opts DeleteOptions) (err error) {
+	ctx, end := c.operation(ctx, "DeleteWithOptions", "network", network)
+	defer end(&err)
+
+	if err := validateNetwork(network); err != nil {
+		return err
+	}
+
+	resp, err := c.post(ctx, "delNet", opts.values(c.Root, network), nil)
+
+	if err != nil {
+		return err
+	}
+
+	if resp.Status() != 200 {
+		return c.responseError("delete", resp)
+	}
+
+	return nil
+}
+
+func (c *WebClient) Add(ctx context.Context, network, description string, tags []string) (err error) {
+	ctx, end := c.operation(ctx, "Add", "network", network)
+	defer end(&err)
+
+	if err := validateNetwork(network); err != nil {
+		return err
+	}
+
+	tagValues, err := c.tagValues(tags)
+	if err != nil {
+		return err
+	}
+
+	if c.overlapCheck {
+		if err := checkOverlap(ctx, c, network); err != nil {
+			return err
+		}
+	}
+
+	resp, err := c.post(ctx, "addNet",
 		&neturl.Values{
 			"rootName":    {c.Root},
 			"network":     {network},
-			"networkLock": {"1"},
+			"description": {description},
+			"tags":        tagValues,
 		},
-		nil,
 		nil)
 
 	if err != nil {
@@ -166,21 +483,33 @@ func (c *WebClient) Delete(network string) (err error) {
 	}
 
 	if resp.Status() != 200 {
-		return fmt.Errorf("delete failed: %s", resp.RawText())
+		return c.responseError("assignment", resp)
 	}
 
-	return
+	return nil
 }
 
-func (c *WebClient) Add(network, description string, tags []string) error {
-	resp, err := c.napping.Get(c.URL+"/RESTWrapper/addNet",
+// Update replaces the description and tags of an existing network.
+func (c *WebClient) Update(ctx context.Context, network, description string, tags []string) (err error) {
+	ctx, end := c.operation(ctx, "Update", "network", network)
+	defer end(&err)
+
+	if err := validateNetwork(network); err != nil {
+		return err
+	}
+
+	tagValues, err := c.tagValues(tags)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.post(ctx, "editNet",
 		&neturl.Values{
 			"rootName":    {c.Root},
 			"network":     {network},
 			"description": {description},
-			"tags":        {strings.Join(tags, " ")},
+			"tags":        tagValues,
 		},
-		nil,
 		nil)
 
 	if err != nil {
@@ -188,40 +517,141 @@ func (c *WebClient) Add(network, description string, tags []string) error {
 	}
 
 	if resp.Status() != 200 {
-		return fmt.Errorf("assignment failed: %s", resp.RawText())
+		return c.responseError("update", resp)
 	}
 
 	return nil
 }
 
-func (c *WebClient) Search(description string, exact bool) (networks []Network, err error) {
+func (c *WebClient) Search(ctx context.Context, description string, exact bool) (networks []Network, err error) {
+	ctx, end := c.operation(ctx, "Search")
+	defer end(&err)
+
+	return c.search(ctx, description, exact, nil)
+}
+
+// search searches for description, sending the extra parameters as well.
+func (c *WebClient) search(ctx context.Context, description string, exact bool, extra neturl.Values) (networks []Network, err error) {
 	values := neturl.Values{
 		"rootName":    {c.Root},
 		"search":      {description},
 		"withDetails": {"1"},
 	}
+	// Without exact search on the server, matches are filtered here.
+	filter := false
 	if exact {
-		values["exact"] = []string{"true"}
+		supported, err := c.supports(ctx, CapExactSearch)
+		if err != nil {
+			return []Network{}, err
+		}
+		if supported {
+			values["exact"] = []string{"true"}
+		} else {
+			filter = true
+		}
 	}
-	resp, err := c.napping.Get(c.URL+"/RESTWrapper/search", &values, &networks, nil)
+	for k, v := range extra {
+		values[k] = v
+	}
+	resp, networks, err := c.getNetworks(ctx, "search", &values)
 
 	if err != nil {
 		return []Network{}, err
 	}
 
 	if resp.Status() != 200 {
-		return []Network{}, fmt.Errorf("search failed: %s", resp.RawText())
+		return []Network{}, c.responseError("search", resp)
 	}
 
+	if filter {
+		networks = Networks(networks).filter(func(n Network) bool {
+			return n.Description == description
+		})
+	}
 	return
 
 }
 
-func (c *WebClient) Reset() error {
-	return fmt.Errorf("Reset() not implemented in haci.WebClient")
+// ListWithOptions lists the subnets of supernet as selected by opts. With
+// WithServerPaging, plain Offset/Limit requests are passed on to HaCi;
+// everything else is applied by the client.
+func (c *WebClient) ListWithOptions(ctx context.Context, supernet string, opts Options) (page Page, err error) {
+	ctx, end := c.operation(ctx, "ListWithOptions", "supernet", supernet)
+	defer end(&err)
+
+	if c.serverPaging && opts.pageable() {
+		return opts.serverPage(func(paging neturl.Values) ([]Network, error) {
+			return c.list(ctx, supernet, paging)
+		})
+	}
+
+	networks, err := c.List(ctx, supernet)
+	if err != nil {
+		return Page{}, err
+	}
+	return opts.apply(networks)
+}
+
+// SearchWithOptions searches like Search and returns the results as
+// selected by opts, see ListWithOptions.
+func (c *WebClient) SearchWithOptions(ctx context.Context, description string, exact bool, opts Options) (page Page, err error) {
+	ctx, end := c.operation(ctx, "SearchWithOptions")
+	defer end(&err)
+
+	if c.serverPaging && opts.pageable() {
+		return opts.serverPage(func(paging neturl.Values) ([]Network, error) {
+			return c.search(ctx, description, exact, paging)
+		})
+	}
+
+	networks, err := c.Search(ctx, description, exact)
+	if err != nil {
+		return Page{}, err
+	}
+	return opts.apply(networks)
+}
+
+// Reset deletes all networks in the root. To prevent accidental wipes, the
+// client must have been created with WithAllowReset for its root.
+func (c *WebClient) Reset(ctx context.Context) (err error) {
+	ctx, end := c.operation(ctx, "Reset")
+	defer end(&err)
+
+	if c.resetRoot == "" || c.resetRoot != c.Root {
+		return fmt.Errorf("Reset() of root %s not allowed, use WithAllowReset", c.Root)
+	}
+
+	networks, err := c.Search(ctx, "", false)
+	if err != nil {
+		return err
+	}
+
+	// Delete the most specific networks first so no parent is removed
+	// while it still has children.
+	sort.SliceStable(networks, func(i, j int) bool {
+		return prefixLen(networks[i].Network) > prefixLen(networks[j].Network)
+	})
+
+	for _, n := range networks {
+		if err := c.Delete(ctx, n.Network); err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *FakeClient) Get(ctx context.Context, network string) (Network, error) {
+	if err := c.inject(ctx, "Get", network); err != nil {
+		return Network{}, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.get(network)
 }
 
-func (c *FakeClient) Get(network string) (Network, error) {
+func (c *FakeClient) get(network string) (Network, error) {
 	if n, ok := c.Added[network]; ok {
 		return n, nil
 	}
@@ -231,59 +661,141 @@ func (c *FakeClient) Get(network string) (Network, error) {
 			return n, nil
 		}
 	}
-	return Network{}, fmt.Errorf("network %s not found", network)
+	return Network{}, fakeError("lookup", "getNetworkDetails", http.StatusNotFound, "network %s not found", network)
+}
+
+// WithRoot returns a client for another root that shares the connections,
+// configuration and state (like the circuit breaker) of c.
+func (c *WebClient) WithRoot(root string) *WebClient {
+	clone := *c
+	clone.Root = root
+	return &clone
 }
 
 func (c *WebClient) String() string {
 	return fmt.Sprintf("HaCi at %s(%s)", c.URL, c.Root)
 }
 
-func (c *FakeClient) List(supernet string) (networks []Network, err error) {
+func (c *FakeClient) List(ctx context.Context, supernet string) ([]Network, error) {
+	if err := c.inject(ctx, "List", supernet); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.list(ctx, supernet)
+}
+
+func (c *FakeClient) list(ctx context.Context, supernet string) (networks []Network, err error) {
+	networks, _ = c.children(ctx, supernet)
+	return Networks(networks).SortByCIDR(), nil
+}
+
+// children returns the subnets of supernet like list, but unsorted.
+func (c *FakeClient) children(ctx context.Context, supernet string) (networks []Network, err error) {
 	if s, ok := c.Supernets[supernet]; ok {
 		for _, n := range s.Networks {
 			networks = append(networks, n)
 		}
 	}
-
-	return
+	return networks, nil
 }
 
-func (c *FakeClient) Assign(supernet, description string, cidr int, tags []string) (network1 Network, err error) {
+// networks returns all networks of the fake, sorted like list.
+func (c *FakeClient) networks() Networks {
+	all := Networks{}
+	for _, n := range c.Added {
+		all = append(all, n)
+	}
+	for _, s := range c.Supernets {
+		for _, n := range s.Networks {
+			all = append(all, n)
+		}
+	}
+	return all.SortByCIDR()
+}
 
-	ip, net, err := net.ParseCIDR(supernet)
+func (c *FakeClient) Assign(ctx context.Context, supernet, description string, cidr int, tags []string) (network1 Network, err error) {
+	call, err := c.injectCall(ctx, "Assign", supernet, description, cidr, tags)
 	if err != nil {
 		return Network{}, err
 	}
+	defer c.returned(call, &err)
 
-	if _, ok := c.Supernets[supernet]; !ok {
-		last := ip
-		if c.UseFirst {
-			last = ccidr.Dec(last)
-		}
-		c.Supernets[supernet] = &FakeSupernet{Network: *net, Networks: map[string]Network{}, Last: last}
+	if err := validateSubnet(supernet, cidr); err != nil {
+		return Network{}, err
 	}
-
-	_, l := ccidr.AddressRange(net)
-	if l.Equal(c.Supernets[supernet].Last) {
-		return Network{}, fmt.Errorf("out of addresses in %s", supernet)
+	if err := validateTags(tags, false); err != nil {
+		return Network{}, err
 	}
 
-	newip := ccidr.Inc(c.Supernets[supernet].Last)
-	netname := fmt.Sprintf("%s/32", newip.String())
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	network1 = Network{
-		Network:     netname,
-		Description: description,
-		Tags:        tags,
+	netname, err := c.peekFree(ctx, supernet, cidr)
+	if errors.Is(err, ErrNoFreeSubnet) && c.Strategy == FirstFit {
+		// HaCi itself reports this when assigning.
+		return Network{}, fakeError("assignment", "assignFreeSubnet", http.StatusBadRequest, "no free subnet /%d in %s", cidr, supernet)
 	}
+	if err != nil {
+		return Network{}, err
+	}
+	if err := c.checkQuota(supernet, netname); err != nil {
+		return Network{}, err
+	}
+
+	s := c.supernet(supernet)
+	network1 = c.created(Network{Network: netname, Description: description, Tags: tags})
 
-	c.Supernets[supernet].Networks[netname] = network1
-	c.Supernets[supernet].Last = newip
+	s.Networks[netname] = network1
 
 	return
 }
 
-func (c *FakeClient) Delete(network string) error {
+// created fills in the fields HaCi sets for a new network.
+func (c *FakeClient) created(n Network) Network {
+	c.lastID++
+	n.ID = json.Number(strconv.FormatInt(c.lastID, 10))
+	n.State = "UNSPECIFIED"
+	n.CreateDate, n.CreateFrom = c.stamp()
+	return n
+}
+
+// stamp returns the current date, formatted like HaCi does, and the user.
+func (c *FakeClient) stamp() (date, user string) {
+	now := time.Now
+	if c.Clock != nil {
+		now = c.Clock
+	}
+	user = c.User
+	if user == "" {
+		user = "fake"
+	}
+	return now().Format(dateFormats[0]), user
+}
+
+func (c *FakeClient) DeleteWithOptions(ctx context.Context, network string, opts DeleteOptions) error {
+	return c.Delete(ctx, network)
+}
+
+func (c *FakeClient) Delete(ctx context.Context, network string) (err error) {
+	call, err := c.injectCall(ctx, "Delete", network)
+	if err != nil {
+		return err
+	}
+	defer c.returned(call, &err)
+
+	if err := validateNetwork(network); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, err := c.get(network); err != nil {
+		return fakeError("delete", "delNet", http.StatusNotFound, "network %s not found", network)
+	}
 	for _, s := range c.Supernets {
 		delete(s.Networks, network)
 	}
@@ -291,37 +803,165 @@ func (c *FakeClient) Delete(network string) error {
 	return nil
 }
 
-func (c *FakeClient) Add(network, description string, tags []string) error {
-	for _, s := range c.Supernets {
-		if _, exists := s.Networks[network]; exists {
-			return fmt.Errorf("network %s already exists", network)
-		}
+func (c *FakeClient) Add(ctx context.Context, network, description string, tags []string) (err error) {
+	call, err := c.injectCall(ctx, "Add", network, description, tags)
+	if err != nil {
+		return err
 	}
-	if _, exists := c.Added[network]; exists {
-		return fmt.Errorf("network %s already exists", network)
+	defer c.returned(call, &err)
+
+	if err := validateNetwork(network); err != nil {
+		return err
+	}
+	if err := validateTags(tags, false); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, err := c.get(network); err == nil {
+		return fakeError("assignment", "addNet", http.StatusConflict, "network %s already exists", network)
+	}
+	inside := c.networks().filter(func(n Network) bool {
+		return containsCIDR(network, n.Network)
+	})
+	if c.OverlapCheck && len(inside) > 0 {
+		return &OverlapError{Network: network, Conflicts: inside}
+	}
+
+	parent := c.parent(network)
+	if err := c.checkQuota(parent, network); err != nil {
+		return err
+	}
+
+	siblings := c.Added
+	if parent != "" {
+		siblings = c.supernet(parent).Networks
+	}
+	siblings[network] = c.created(Network{Network: network, Description: description, Tags: tags})
+
+	// Like HaCi, nest the networks inside the new one below it.
+	for _, n := range inside {
+		if _, ok := siblings[n.Network]; ok {
+			delete(siblings, n.Network)
+			c.supernet(network).Networks[n.Network] = n
+		}
 	}
-	c.Added[network] = Network{Network: network, Description: description, Tags: tags}
 	return nil
 }
 
-func (c *FakeClient) Search(description string, exact bool) (networks []Network, err error) {
-	for _, n := range c.Added {
-		if exact && n.Description == description || !exact && strings.Contains(n.Description, description) {
-			networks = append(networks, n)
+// supernet returns the subnets of supernet, which are created if needed.
+func (c *FakeClient) supernet(supernet string) *FakeSupernet {
+	if _, ok := c.Supernets[supernet]; !ok {
+		_, n, _ := net.ParseCIDR(supernet)
+		c.Supernets[supernet] = &FakeSupernet{Network: *n, Networks: map[string]Network{}}
+	}
+	return c.Supernets[supernet]
+}
+
+// parent returns the most specific supernet or network containing network,
+// "" if there is none. Added networks are listed under it, like in HaCi.
+func (c *FakeClient) parent(network string) string {
+	parent := ""
+	consider := func(cidr string) {
+		if containsCIDR(cidr, network) && (parent == "" || prefixLen(cidr) > prefixLen(parent)) {
+			parent = cidr
+		}
+	}
+
+	for cidr := range c.Added {
+		consider(cidr)
+	}
+	for supernet, s := range c.Supernets {
+		consider(supernet)
+		for cidr := range s.Networks {
+			consider(cidr)
 		}
 	}
+	return parent
+}
+
+func (c *FakeClient) Update(ctx context.Context, network, description string, tags []string) (err error) {
+	call, err := c.injectCall(ctx, "Update", network, description, tags)
+	if err != nil {
+		return err
+	}
+	defer c.returned(call, &err)
+
+	if err := validateNetwork(network); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.update(network, description, tags)
+}
+
+func (c *FakeClient) update(network, description string, tags []string) error {
+	if err := validateTags(tags, false); err != nil {
+		return err
+	}
+	date, user := c.stamp()
+	if n, ok := c.Added[network]; ok {
+		n.Description, n.Tags = description, tags
+		n.ModifyDate, n.ModifyFrom = date, user
+		c.Added[network] = n
+		return nil
+	}
 
 	for _, s := range c.Supernets {
-		for _, n := range s.Networks {
-			if exact && n.Description == description || !exact && strings.Contains(n.Description, description) {
-				networks = append(networks, n)
-			}
+		if n, ok := s.Networks[network]; ok {
+			n.Description, n.Tags = description, tags
+			n.ModifyDate, n.ModifyFrom = date, user
+			s.Networks[network] = n
+			return nil
+		}
+	}
+	return fakeError("update", "editNet", http.StatusNotFound, "network %s not found", network)
+}
+
+func (c *FakeClient) Search(ctx context.Context, description string, exact bool) (networks []Network, err error) {
+	if err := c.inject(ctx, "Search", description, exact); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, n := range c.networks() {
+		if exact && n.Description == description || !exact && strings.Contains(n.Description, description) {
+			networks = append(networks, n)
 		}
 	}
 	return
 }
 
-func (c *FakeClient) Reset() error {
+func (c *FakeClient) ListWithOptions(ctx context.Context, supernet string, opts Options) (Page, error) {
+	networks, err := c.List(ctx, supernet)
+	if err != nil {
+		return Page{}, err
+	}
+	return opts.apply(networks)
+}
+
+func (c *FakeClient) SearchWithOptions(ctx context.Context, description string, exact bool, opts Options) (Page, error) {
+	networks, err := c.Search(ctx, description, exact)
+	if err != nil {
+		return Page{}, err
+	}
+	return opts.apply(networks)
+}
+
+func (c *FakeClient) Reset(ctx context.Context) error {
+	if err := c.inject(ctx, "Reset"); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	c.Supernets = map[string]*FakeSupernet{}
 	c.Added = map[string]Network{}
 
@@ -0,0 +1,417 @@
+package hacitest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/Nexinto/go-haci-client/haci"
+)
+
+// RunClientTests checks that a Client implementation behaves like HaCi:
+// how networks are assigned, listed, searched, changed and deleted, and
+// which errors are returned. newClient is called for every test and must
+// return a client working on an empty root.
+//
+// Run it against your own implementations and wrappers of Client, e.g.
+//
+//	func TestClient(t *testing.T) {
+//		hacitest.RunClientTests(t, func(t *testing.T) haci.Client {
+//			return haci.NewFakeClient()
+//		})
+//	}
+func RunClientTests(t *testing.T, newClient func(t *testing.T) haci.Client) {
+	for _, test := range clientTests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			test.run(t, context.Background(), newClient(t))
+		})
+	}
+}
+
+// supernet is where the tests assign and add networks.
+const supernet = "10.99.0.0/24"
+
+var clientTests = []struct {
+	name string
+	run  func(t *testing.T, ctx context.Context, c haci.Client)
+}{
+	{"AddAndGet", func(t *testing.T, ctx context.Context, c haci.Client) {
+		mustAdd(t, ctx, c, supernet, "parent", nil)
+		mustAdd(t, ctx, c, "10.99.0.64/26", "added", []string{"a", "b"})
+
+		n, err := c.Get(ctx, "10.99.0.64/26")
+		if err != nil {
+			t.Fatalf("Get: %s", err)
+		}
+		expectNetwork(t, n, "10.99.0.64/26", "added", []string{"a", "b"})
+	}},
+	{"AddExisting", func(t *testing.T, ctx context.Context, c haci.Client) {
+		mustAdd(t, ctx, c, supernet, "parent", nil)
+		expectError(t, c.Add(ctx, supernet, "again", nil), haci.ErrAlreadyExists)
+	}},
+	{"AddInvalid", func(t *testing.T, ctx context.Context, c haci.Client) {
+		expectError(t, c.Add(ctx, "10.99.0.1/24", "host bits", nil), haci.ErrInvalidNetwork)
+		expectError(t, c.Add(ctx, "10.99.0.0", "no prefix", nil), haci.ErrInvalidNetwork)
+	}},
+	{"GetMissing", func(t *testing.T, ctx context.Context, c haci.Client) {
+		_, err := c.Get(ctx, "10.99.0.128/25")
+		expectError(t, err, haci.ErrNotFound)
+	}},
+	{"GetByIPAndParent", func(t *testing.T, ctx context.Context, c haci.Client) {
+		mustAdd(t, ctx, c, supernet, "parent", nil)
+		mustAdd(t, ctx, c, "10.99.0.64/26", "child", nil)
+
+		for ip, want := range map[string]string{"10.99.0.70": "10.99.0.64/26", "10.99.0.1": supernet} {
+			n, err := c.GetByIP(ctx, ip)
+			if err != nil {
+				t.Fatalf("GetByIP %s: %s", ip, err)
+			}
+			if n.Network != want {
+				t.Errorf("GetByIP %s returned %s, want %s", ip, n.Network, want)
+			}
+		}
+		_, err := c.GetByIP(ctx, "10.98.0.1")
+		expectError(t, err, haci.ErrNotFound)
+
+		n, err := c.GetParent(ctx, "10.99.0.64/26")
+		if err != nil {
+			t.Fatalf("GetParent: %s", err)
+		}
+		if n.Network != supernet {
+			t.Errorf("GetParent returned %s, want %s", n.Network, supernet)
+		}
+	}},
+	{"AssignFirstFree", func(t *testing.T, ctx context.Context, c haci.Client) {
+		mustAdd(t, ctx, c, supernet, "parent", nil)
+		for _, want := range []string{"10.99.0.0/28", "10.99.0.16/28", "10.99.0.32/28"} {
+			n, err := c.Assign(ctx, supernet, "assigned", 28, []string{"t"})
+			if err != nil {
+				t.Fatalf("Assign: %s", err)
+			}
+			expectNetwork(t, n, want, "assigned", []string{"t"})
+		}
+	}},
+	{"AssignSkipsTaken", func(t *testing.T, ctx context.Context, c haci.Client) {
+		mustAdd(t, ctx, c, supernet, "parent", nil)
+		mustAdd(t, ctx, c, "10.99.0.0/27", "taken", nil)
+		n, err := c.Assign(ctx, supernet, "assigned", 28, nil)
+		if err != nil {
+			t.Fatalf("Assign: %s", err)
+		}
+		if n.Network != "10.99.0.32/28" {
+			t.Errorf("assigned %s, want 10.99.0.32/28", n.Network)
+		}
+	}},
+	{"AssignExhausted", func(t *testing.T, ctx context.Context, c haci.Client) {
+		mustAdd(t, ctx, c, supernet, "parent", nil)
+		for i := 0; i < 2; i++ {
+			if _, err := c.Assign(ctx, supernet, "half", 25, nil); err != nil {
+				t.Fatalf("Assign: %s", err)
+			}
+		}
+		_, err := c.Assign(ctx, supernet, "none left", 25, nil)
+		expectError(t, err, haci.ErrNoFreeSubnet)
+	}},
+	{"AssignReusesFreed", func(t *testing.T, ctx context.Context, c haci.Client) {
+		mustAdd(t, ctx, c, supernet, "parent", nil)
+		for i := 0; i < 4; i++ {
+			if _, err := c.Assign(ctx, supernet, "quarter", 26, nil); err != nil {
+				t.Fatalf("Assign: %s", err)
+			}
+		}
+		if err := c.Delete(ctx, "10.99.0.64/26"); err != nil {
+			t.Fatalf("Delete: %s", err)
+		}
+
+		free, err := c.FreeSubnets(ctx, supernet, 26)
+		if err != nil {
+			t.Fatalf("FreeSubnets: %s", err)
+		}
+		if !equal(free, []string{"10.99.0.64/26"}) {
+			t.Errorf("got free subnets %v, want [10.99.0.64/26]", free)
+		}
+		peeked, err := c.PeekFree(ctx, supernet, 26)
+		if err != nil {
+			t.Fatalf("PeekFree: %s", err)
+		}
+		n, err := c.Assign(ctx, supernet, "again", 26, nil)
+		if err != nil {
+			t.Fatalf("Assign: %s", err)
+		}
+		if n.Network != "10.99.0.64/26" || peeked != n.Network {
+			t.Errorf("assigned %s after PeekFree returned %s, want 10.99.0.64/26", n.Network, peeked)
+		}
+	}},
+	{"AssignInvalidPrefixLen", func(t *testing.T, ctx context.Context, c haci.Client) {
+		mustAdd(t, ctx, c, supernet, "parent", nil)
+		_, err := c.Assign(ctx, supernet, "too big", 16, nil)
+		expectError(t, err, haci.ErrInvalidPrefixLen)
+	}},
+	{"AssignMany", func(t *testing.T, ctx context.Context, c haci.Client) {
+		mustAdd(t, ctx, c, supernet, "parent", nil)
+		assigned, err := c.AssignMany(ctx, supernet, "many", 28, 3, nil)
+		if err != nil {
+			t.Fatalf("AssignMany: %s", err)
+		}
+		expectCIDRs(t, assigned, "10.99.0.0/28", "10.99.0.16/28", "10.99.0.32/28")
+	}},
+	{"AssignManyRollsBack", func(t *testing.T, ctx context.Context, c haci.Client) {
+		mustAdd(t, ctx, c, supernet, "parent", nil)
+		_, err := c.AssignMany(ctx, supernet, "too many", 25, 3, nil)
+		expectError(t, err, haci.ErrNoFreeSubnet)
+
+		networks, err := c.List(ctx, supernet)
+		if err != nil {
+			t.Fatalf("List: %s", err)
+		}
+		expectCIDRs(t, networks)
+	}},
+	{"AssignBlock", func(t *testing.T, ctx context.Context, c haci.Client) {
+		mustAdd(t, ctx, c, supernet, "parent", nil)
+		mustAdd(t, ctx, c, "10.99.0.0/28", "taken", nil)
+		assigned, err := c.AssignBlock(ctx, supernet, "block", 28, 2, nil)
+		if err != nil {
+			t.Fatalf("AssignBlock: %s", err)
+		}
+		expectCIDRs(t, assigned, "10.99.0.32/28", "10.99.0.48/28")
+	}},
+	{"AssignOrGet", func(t *testing.T, ctx context.Context, c haci.Client) {
+		mustAdd(t, ctx, c, supernet, "parent", nil)
+		first, err := c.AssignOrGet(ctx, supernet, "unique", 28, nil)
+		if err != nil {
+			t.Fatalf("AssignOrGet: %s", err)
+		}
+		second, err := c.AssignOrGet(ctx, supernet, "unique", 28, nil)
+		if err != nil {
+			t.Fatalf("AssignOrGet: %s", err)
+		}
+		if first.Network != "10.99.0.0/28" || second.Network != first.Network {
+			t.Errorf("got %s and then %s, want 10.99.0.0/28 twice", first.Network, second.Network)
+		}
+	}},
+	{"ListSorted", func(t *testing.T, ctx context.Context, c haci.Client) {
+		mustAdd(t, ctx, c, supernet, "parent", nil)
+		for _, n := range []string{"10.99.0.128/25", "10.99.0.0/26", "10.99.0.64/26"} {
+			mustAdd(t, ctx, c, n, "child", nil)
+		}
+
+		networks, err := c.List(ctx, supernet)
+		if err != nil {
+			t.Fatalf("List: %s", err)
+		}
+		expectCIDRs(t, networks, "10.99.0.0/26", "10.99.0.64/26", "10.99.0.128/25")
+	}},
+	{"Update", func(t *testing.T, ctx context.Context, c haci.Client) {
+		mustAdd(t, ctx, c, supernet, "before", []string{"old"})
+		if err := c.Update(ctx, supernet, "after", []string{"new", "newer"}); err != nil {
+			t.Fatalf("Update: %s", err)
+		}
+
+		n, err := c.Get(ctx, supernet)
+		if err != nil {
+			t.Fatalf("Get: %s", err)
+		}
+		expectNetwork(t, n, supernet, "after", []string{"new", "newer"})
+	}},
+	{"UpdateMissing", func(t *testing.T, ctx context.Context, c haci.Client) {
+		expectError(t, c.Update(ctx, supernet, "missing", nil), haci.ErrNotFound)
+	}},
+	{"AddTags", func(t *testing.T, ctx context.Context, c haci.Client) {
+		mustAdd(t, ctx, c, supernet, "tagged", []string{"a"})
+		if err := c.AddTags(ctx, supernet, []string{"b", "a"}); err != nil {
+			t.Fatalf("AddTags: %s", err)
+		}
+		n, err := c.Get(ctx, supernet)
+		if err != nil {
+			t.Fatalf("Get: %s", err)
+		}
+		expectNetwork(t, n, supernet, "tagged", []string{"a", "b"})
+	}},
+	{"RemoveTags", func(t *testing.T, ctx context.Context, c haci.Client) {
+		mustAdd(t, ctx, c, supernet, "tagged", []string{"a", "b", "c"})
+		if err := c.RemoveTags(ctx, supernet, []string{"b", "missing"}); err != nil {
+			t.Fatalf("RemoveTags: %s", err)
+		}
+		n, err := c.Get(ctx, supernet)
+		if err != nil {
+			t.Fatalf("Get: %s", err)
+		}
+		expectNetwork(t, n, supernet, "tagged", []string{"a", "c"})
+	}},
+	{"InvalidTags", func(t *testing.T, ctx context.Context, c haci.Client) {
+		expectError(t, c.Add(ctx, supernet, "parent", []string{" padded"}), haci.ErrInvalidTag)
+		mustAdd(t, ctx, c, supernet, "parent", nil)
+		_, err := c.Assign(ctx, supernet, "empty tag", 28, []string{""})
+		expectError(t, err, haci.ErrInvalidTag)
+		expectError(t, c.Update(ctx, supernet, "control", []string{"a\x00"}), haci.ErrInvalidTag)
+		expectError(t, c.AddTags(ctx, supernet, []string{""}), haci.ErrInvalidTag)
+	}},
+	{"Move", func(t *testing.T, ctx context.Context, c haci.Client) {
+		target := "conformance-move-target"
+		if err := c.CreateRoot(ctx, target, "", false); err != nil && !errors.Is(err, haci.ErrAlreadyExists) {
+			t.Fatalf("CreateRoot: %s", err)
+		}
+		t.Cleanup(func() { c.DeleteRoot(context.Background(), target) })
+
+		mustAdd(t, ctx, c, supernet, "parent", []string{"p"})
+		mustAdd(t, ctx, c, "10.99.0.0/26", "child", []string{"a", "b"})
+		mustAdd(t, ctx, c, "10.99.0.128/26", "single", nil)
+
+		if err := c.Move(ctx, "10.99.0.128/26", target, false); err != nil {
+			t.Fatalf("Move: %s", err)
+		}
+		_, err := c.Get(ctx, "10.99.0.128/26")
+		expectError(t, err, haci.ErrNotFound)
+		moved := rootNetworks(t, ctx, c, target)
+		expectNetwork(t, moved["10.99.0.128/26"], "10.99.0.128/26", "single", nil)
+		if len(moved) != 1 {
+			t.Errorf("moved %d networks, want 1", len(moved))
+		}
+
+		if err := c.Move(ctx, supernet, target, true); err != nil {
+			t.Fatalf("Move: %s", err)
+		}
+		for _, n := range []string{supernet, "10.99.0.0/26"} {
+			_, err := c.Get(ctx, n)
+			expectError(t, err, haci.ErrNotFound)
+		}
+		moved = rootNetworks(t, ctx, c, target)
+		expectNetwork(t, moved[supernet], supernet, "parent", []string{"p"})
+		expectNetwork(t, moved["10.99.0.0/26"], "10.99.0.0/26", "child", []string{"a", "b"})
+		if len(moved) != 3 {
+			t.Errorf("target root has %d networks, want 3", len(moved))
+		}
+
+		expectError(t, c.Move(ctx, "10.99.1.0/24", target, false), haci.ErrNotFound)
+	}},
+	{"Delete", func(t *testing.T, ctx context.Context, c haci.Client) {
+		mustAdd(t, ctx, c, supernet, "parent", nil)
+		mustAdd(t, ctx, c, "10.99.0.0/26", "child", nil)
+		if err := c.Delete(ctx, "10.99.0.0/26"); err != nil {
+			t.Fatalf("Delete: %s", err)
+		}
+
+		_, err := c.Get(ctx, "10.99.0.0/26")
+		expectError(t, err, haci.ErrNotFound)
+		networks, err := c.List(ctx, supernet)
+		if err != nil {
+			t.Fatalf("List: %s", err)
+		}
+		expectCIDRs(t, networks)
+	}},
+	{"DeleteMissing", func(t *testing.T, ctx context.Context, c haci.Client) {
+		expectError(t, c.Delete(ctx, supernet), haci.ErrNotFound)
+	}},
+	{"Search", func(t *testing.T, ctx context.Context, c haci.Client) {
+		mustAdd(t, ctx, c, supernet, "parent", nil)
+		mustAdd(t, ctx, c, "10.99.0.0/26", "web frontend", nil)
+		mustAdd(t, ctx, c, "10.99.0.64/26", "web", nil)
+
+		found, err := c.Search(ctx, "web", false)
+		if err != nil {
+			t.Fatalf("Search: %s", err)
+		}
+		expectCIDRs(t, found, "10.99.0.0/26", "10.99.0.64/26")
+
+		found, err = c.Search(ctx, "web", true)
+		if err != nil {
+			t.Fatalf("Search: %s", err)
+		}
+		expectCIDRs(t, found, "10.99.0.64/26")
+	}},
+	{"SearchTags", func(t *testing.T, ctx context.Context, c haci.Client) {
+		mustAdd(t, ctx, c, supernet, "parent", nil)
+		mustAdd(t, ctx, c, "10.99.0.0/26", "a", []string{"x", "y"})
+		mustAdd(t, ctx, c, "10.99.0.64/26", "b", []string{"y"})
+
+		found, err := c.SearchTags(ctx, []string{"x", "y"}, true)
+		if err != nil {
+			t.Fatalf("SearchTags: %s", err)
+		}
+		expectCIDRs(t, found, "10.99.0.0/26")
+
+		found, err = c.SearchTags(ctx, []string{"x", "y"}, false)
+		if err != nil {
+			t.Fatalf("SearchTags: %s", err)
+		}
+		expectCIDRs(t, found, "10.99.0.0/26", "10.99.0.64/26")
+	}},
+}
+
+func mustAdd(t *testing.T, ctx context.Context, c haci.Client, network, description string, tags []string) {
+	t.Helper()
+
+	if err := c.Add(ctx, network, description, tags); err != nil {
+		t.Fatalf("Add %s: %s", network, err)
+	}
+}
+
+// rootNetworks returns the networks of root, as exported by c.
+func rootNetworks(t *testing.T, ctx context.Context, c haci.Client, root string) map[string]haci.Network {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := c.Export(ctx, root, &buf, haci.ExportJSON); err != nil {
+		t.Fatalf("Export %s: %s", root, err)
+	}
+	var export struct {
+		Networks []haci.Network `json:"networks"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &export); err != nil {
+		t.Fatalf("Export %s: %s", root, err)
+	}
+	networks := map[string]haci.Network{}
+	for _, n := range export.Networks {
+		networks[n.Network] = n
+	}
+	return networks
+}
+
+func expectError(t *testing.T, err, want error) {
+	t.Helper()
+
+	if !errors.Is(err, want) {
+		t.Errorf("got error %v, want one matching %q", err, want)
+	}
+}
+
+func expectNetwork(t *testing.T, n haci.Network, network, description string, tags []string) {
+	t.Helper()
+
+	got := append([]string{}, n.Tags...)
+	want := append([]string{}, tags...)
+	sort.Strings(got)
+	sort.Strings(want)
+	if n.Network != network || n.Description != description || !equal(got, want) {
+		t.Errorf("got %s %q %v, want %s %q %v", n.Network, n.Description, n.Tags, network, description, tags)
+	}
+}
+
+// expectCIDRs checks the networks and their order.
+func expectCIDRs(t *testing.T, networks []haci.Network, cidrs ...string) {
+	t.Helper()
+
+	got := []string{}
+	for _, n := range networks {
+		got = append(got, n.Network)
+	}
+	if !equal(got, cidrs) {
+		t.Errorf("got networks %v, want %v", got, cidrs)
+	}
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,26 @@
+package hacitest_test
+
+import (
+	"testing"
+
+	"github.com/Nexinto/go-haci-client/haci"
+	"github.com/Nexinto/go-haci-client/haci/hacitest"
+)
+
+func TestFakeClient(t *testing.T) {
+	hacitest.RunClientTests(t, func(t *testing.T) haci.Client {
+		return haci.NewFakeClient()
+	})
+}
+
+func TestWebClient(t *testing.T) {
+	hacitest.RunClientTests(t, func(t *testing.T) haci.Client {
+		s := hacitest.NewServer("test")
+		t.Cleanup(s.Close)
+		c, err := haci.NewWebClient(s.URL, haci.WithRoot("test"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	})
+}
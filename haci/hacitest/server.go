@@ -0,0 +1,425 @@
+// Package hacitest provides a HaCi server for tests of code using
+// haci.WebClient.
+//
+// The server implements the RESTWrapper endpoints used by WebClient on top
+// of a haci.FakeClient per root, so it assigns, nests and rejects networks
+// like the fake does. Responses are JSON, errors are JSON objects with an
+// "error" message like the ones of newer HaCi versions, and responses are
+// compressed if the client accepts gzip.
+package hacitest
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Nexinto/go-haci-client/haci"
+)
+
+// DefaultVersion is the HaCi version the server reports by default.
+const DefaultVersion = "0.98"
+
+// sessionCookie is the cookie HaCi keeps its session ID in.
+const sessionCookie = "CGISESSID"
+
+// Request is a request received by the server.
+type Request struct {
+	Method   string
+	Endpoint string
+	Params   url.Values
+	Header   http.Header
+}
+
+// Server is a HaCi server listening on a local address. It is safe for
+// concurrent use.
+type Server struct {
+	*httptest.Server
+
+	// Version is reported by getVersion. If empty, the endpoint is missing
+	// like on old HaCi versions. It must be set before the first request.
+	Version string
+
+	mu       sync.Mutex
+	roots    *haci.FakeClient
+	networks map[string]*haci.FakeClient
+	failures map[string]failure
+	requests []Request
+
+	username string
+	password string
+	token    string
+	sessions map[string]bool
+}
+
+type failure struct {
+	count  int
+	status int
+}
+
+// NewServer starts a server with the given roots. Requests for other roots
+// fail like on a real server. Call Close when done.
+func NewServer(roots ...string) *Server {
+	s := &Server{
+		Version:  DefaultVersion,
+		roots:    haci.NewFakeClient(),
+		networks: map[string]*haci.FakeClient{},
+		failures: map[string]failure{},
+		sessions: map[string]bool{},
+	}
+	for _, root := range roots {
+		s.roots.CreateRoot(context.Background(), root, "", false)
+		s.networks[root] = haci.NewFakeClient()
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// Fake returns the fake holding the networks of root, to set up or inspect
+// the networks directly. It returns nil if there is no such root.
+func (s *Server) Fake(root string) *haci.FakeClient {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.networks[root]
+}
+
+// RequireBasicAuth makes the server accept only requests authenticated
+// with username and password, either with basic auth or in a session
+// started with the login endpoint.
+func (s *Server) RequireBasicAuth(username, password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.username, s.password = username, password
+}
+
+// RequireToken makes the server accept requests with token as bearer token.
+func (s *Server) RequireToken(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.token = token
+}
+
+// ExpireSessions ends all sessions, so clients have to log in again.
+func (s *Server) ExpireSessions() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessions = map[string]bool{}
+}
+
+// FailNext makes the server answer the next n requests for endpoint, e.g.
+// "assignFreeSubnet", with status and an error message. An empty endpoint
+// matches all endpoints.
+func (s *Server) FailNext(endpoint string, n, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failures[endpoint] = failure{count: n, status: status}
+}
+
+// Requests returns the requests received so far, in order.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Request{}, s.requests...)
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	endpoint := strings.TrimPrefix(r.URL.Path, "/RESTWrapper/")
+	if err := r.ParseForm(); err != nil {
+		s.writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, Request{Method: r.Method, Endpoint: endpoint, Params: r.Form, Header: r.Header.Clone()})
+	status := s.fail(endpoint)
+	authorized := endpoint == "login" || s.authorized(r)
+	s.mu.Unlock()
+
+	if status != 0 {
+		s.writeError(w, r, status, fmt.Sprintf("injected failure of %s", endpoint))
+		return
+	}
+	if !authorized {
+		s.writeError(w, r, http.StatusUnauthorized, "authentication required")
+		return
+	}
+
+	result, err := s.handle(w, r, endpoint)
+	if err != nil {
+		var apiErr *haci.APIError
+		switch {
+		case errors.As(err, &apiErr):
+			s.writeError(w, r, apiErr.StatusCode, apiErr.Message)
+		case errors.Is(err, haci.ErrOverlap), errors.Is(err, haci.ErrQuotaExceeded):
+			s.writeError(w, r, http.StatusConflict, err.Error())
+		default:
+			s.writeError(w, r, http.StatusBadRequest, err.Error())
+		}
+		return
+	}
+	s.write(w, r, http.StatusOK, result)
+}
+
+// fail returns the status of an injected failure for endpoint, 0 if there
+// is none.
+func (s *Server) fail(endpoint string) int {
+	for _, key := range []string{endpoint, ""} {
+		if f, ok := s.failures[key]; ok && f.count > 0 {
+			f.count--
+			s.failures[key] = f
+			return f.status
+		}
+	}
+	return 0
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	if s.username == "" && s.token == "" {
+		return true
+	}
+	if user, pass, ok := r.BasicAuth(); ok && s.username != "" {
+		return user == s.username && pass == s.password
+	}
+	if s.token != "" && r.Header.Get("Authorization") == "Bearer "+s.token {
+		return true
+	}
+	if c, err := r.Cookie(sessionCookie); err == nil {
+		return s.sessions[c.Value]
+	}
+	return false
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request, endpoint string) (interface{}, error) {
+	ctx, params := r.Context(), r.Form
+
+	switch endpoint {
+	case "login":
+		return s.login(w, params)
+	case "logout":
+		return s.logout(r), nil
+	case "getVersion":
+		if s.Version == "" {
+			return nil, &haci.APIError{StatusCode: http.StatusNotFound, Message: "unknown function getVersion"}
+		}
+		return map[string]string{"version": s.Version}, nil
+	case "getRoots":
+		return s.roots.ListRoots(ctx)
+	case "addRoot":
+		return s.addRoot(ctx, params)
+	case "delRoot":
+		return s.delRoot(ctx, params.Get("rootName"))
+	}
+
+	fake, err := s.root(params.Get("rootName"))
+	if err != nil {
+		return nil, err
+	}
+
+	switch endpoint {
+	case "getNetworkDetails":
+		return fake.Get(ctx, params.Get("network"))
+	case "getSubnets":
+		networks, err := fake.List(ctx, params.Get("supernet"))
+		if err != nil {
+			return nil, err
+		}
+		return page(networks, params)
+	case "search":
+		return s.search(ctx, fake, params)
+	case "assignFreeSubnet":
+		cidr, err := strconv.Atoi(params.Get("cidr"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid cidr %q", params.Get("cidr"))
+		}
+		return fake.Assign(ctx, params.Get("supernet"), params.Get("description"), cidr, tags(params))
+	case "addNet":
+		return struct{}{}, fake.Add(ctx, params.Get("network"), params.Get("description"), tags(params))
+	case "editNet":
+		return struct{}{}, fake.Update(ctx, params.Get("network"), params.Get("description"), tags(params))
+	case "delNet":
+		return struct{}{}, fake.Delete(ctx, params.Get("network"))
+	}
+	return nil, &haci.APIError{StatusCode: http.StatusNotFound, Message: "unknown function " + endpoint}
+}
+
+func (s *Server) root(name string) (*haci.FakeClient, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fake, ok := s.networks[name]
+	if !ok {
+		return nil, &haci.APIError{StatusCode: http.StatusNotFound, Message: fmt.Sprintf("root %s not found", name)}
+	}
+	return fake, nil
+}
+
+func (s *Server) addRoot(ctx context.Context, params url.Values) (interface{}, error) {
+	name := params.Get("rootName")
+	if err := s.roots.CreateRoot(ctx, name, params.Get("description"), params.Get("ipv6") == "1"); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.networks[name] = haci.NewFakeClient()
+	return struct{}{}, nil
+}
+
+func (s *Server) delRoot(ctx context.Context, name string) (interface{}, error) {
+	if err := s.roots.DeleteRoot(ctx, name); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.networks, name)
+	return struct{}{}, nil
+}
+
+func (s *Server) login(w http.ResponseWriter, params url.Values) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.username != "" && (params.Get("username") != s.username || params.Get("password") != s.password) {
+		return nil, &haci.APIError{StatusCode: http.StatusUnauthorized, Message: "login failed"}
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	session := hex.EncodeToString(id)
+	s.sessions[session] = true
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: session, Path: "/"})
+	return struct{}{}, nil
+}
+
+func (s *Server) logout(r *http.Request) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if c, err := r.Cookie(sessionCookie); err == nil {
+		delete(s.sessions, c.Value)
+	}
+	return struct{}{}
+}
+
+// search searches by description and, if tags are given, by tags.
+func (s *Server) search(ctx context.Context, fake *haci.FakeClient, params url.Values) (interface{}, error) {
+	description := params.Get("search")
+	exact := params.Get("exact") == "true"
+
+	networks, err := fake.Search(ctx, description, exact)
+	if err != nil {
+		return nil, err
+	}
+	if wanted := tags(params); len(wanted) > 0 {
+		tagged, err := fake.SearchTags(ctx, wanted, params.Get("tagsAll") == "1")
+		if err != nil {
+			return nil, err
+		}
+		found := map[string]bool{}
+		for _, n := range tagged {
+			found[n.Network] = true
+		}
+		matching := []haci.Network{}
+		for _, n := range networks {
+			if found[n.Network] {
+				matching = append(matching, n)
+			}
+		}
+		networks = matching
+	}
+	return page(networks, params)
+}
+
+// tags returns the tags of a request, sent either as one value per tag or
+// as a single space-separated value.
+func tags(params url.Values) []string {
+	values := params["tags"]
+	if len(values) == 1 {
+		return strings.Fields(values[0])
+	}
+	return values
+}
+
+// page applies the offset and limit parameters of a request.
+func page(networks []haci.Network, params url.Values) ([]haci.Network, error) {
+	offset, limit := 0, len(networks)
+	var err error
+	if v := params.Get("offset"); v != "" {
+		if offset, err = strconv.Atoi(v); err != nil || offset < 0 {
+			return nil, fmt.Errorf("invalid offset %q", v)
+		}
+	}
+	if v := params.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 0 {
+			return nil, fmt.Errorf("invalid limit %q", v)
+		}
+	}
+
+	if offset > len(networks) {
+		offset = len(networks)
+	}
+	if offset+limit > len(networks) {
+		limit = len(networks) - offset
+	}
+	if networks == nil {
+		networks = []haci.Network{}
+	}
+	return networks[offset : offset+limit], nil
+}
+
+func (s *Server) writeError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	s.write(w, r, status, map[string]string{"error": message})
+}
+
+// write sends v as JSON. Listings get an ETag and are answered with 304 if
+// the client has them already.
+func (s *Server) write(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	if status == http.StatusOK && strings.HasSuffix(r.URL.Path, "/getSubnets") {
+		sum := sha256.Sum256(body)
+		etag := `"` + hex.EncodeToString(sum[:8]) + `"`
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+		w.WriteHeader(status)
+		w.Write(body)
+		return
+	}
+	w.Header().Set("Content-Encoding", "gzip")
+	w.WriteHeader(status)
+	gz := gzip.NewWriter(w)
+	gz.Write(body)
+	gz.Close()
+}
@@ -0,0 +1,259 @@
+package haci
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ImportMode decides what Import does with networks that exist already
+// with a different description or tags. Networks that exist unchanged are
+// always skipped.
+type ImportMode int
+
+const (
+	// ImportFailOnConflict stops the import with an error matching
+	// ErrAlreadyExists.
+	ImportFailOnConflict ImportMode = iota
+	// ImportSkipExisting keeps the existing network.
+	ImportSkipExisting
+	// ImportOverwrite updates the existing network to the exported
+	// description and tags.
+	ImportOverwrite
+)
+
+// ImportOptions configure Import.
+type ImportOptions struct {
+	// Root is the root to import into. If empty, the root named in a JSON
+	// export is used, or else the root of the client. A missing root is
+	// created.
+	Root string
+
+	// Format is the format of the export, ExportJSON if empty.
+	Format ExportFormat
+
+	Mode ImportMode
+}
+
+// ImportResult counts what Import did.
+type ImportResult struct {
+	Added   int
+	Updated int
+	Skipped int
+}
+
+// Import recreates the networks of an export written by Export, reading
+// them as they are needed. Only networks, descriptions and tags are
+// restored; IDs, dates and states are assigned by HaCi anew. If the import
+// fails, it can be run again with ImportSkipExisting to continue.
+func (c *WebClient) Import(ctx context.Context, r io.Reader, opts ImportOptions) (result ImportResult, err error) {
+	ctx, end := c.operation(ctx, "Import")
+	defer end(&err)
+
+	return importExport(ctx, func(root Root) (Client, error) {
+		if root.Name == "" {
+			return nil, errors.New("no root to import into")
+		}
+		return c.WithRoot(root.Name), ensureRoot(ctx, c, root)
+	}, c.Root, r, opts)
+}
+
+// Import adds the networks of an export to the fake of its root returned
+// by WithRoot, creating the root in Roots if needed. Exports without a
+// root are added to the fake itself.
+func (c *FakeClient) Import(ctx context.Context, r io.Reader, opts ImportOptions) (ImportResult, error) {
+	return importExport(ctx, func(root Root) (Client, error) {
+		if root.Name == "" {
+			return c, nil
+		}
+		return c.WithRoot(root.Name), ensureRoot(ctx, c, root)
+	}, "", r, opts)
+}
+
+// importExport reads an export from r. inRoot returns a client for a root
+// after creating it if needed; defaultRoot is the root used if neither
+// opts nor the export name one.
+func importExport(ctx context.Context, inRoot func(Root) (Client, error), defaultRoot string, r io.Reader, opts ImportOptions) (ImportResult, error) {
+	result := ImportResult{}
+	header := Root{Name: defaultRoot}
+	var target Client
+
+	// The root is opened when the first network is read, after a JSON
+	// export has named it.
+	open := func() error {
+		if target != nil {
+			return nil
+		}
+		if opts.Root != "" {
+			header.Name = opts.Root
+		}
+		var err error
+		target, err = inRoot(header)
+		return err
+	}
+	add := func(n Network) error {
+		if err := open(); err != nil {
+			return err
+		}
+		return importNetwork(ctx, target, n, opts.Mode, &result)
+	}
+
+	var err error
+	switch opts.Format {
+	case ExportJSON, "":
+		err = readJSONExport(r, &header, add)
+	case ExportCSV:
+		err = readCSVExport(r, add)
+	default:
+		err = fmt.Errorf("unknown export format %q", opts.Format)
+	}
+	if err != nil {
+		return result, err
+	}
+	// An export without networks still recreates its root.
+	return result, open()
+}
+
+// ensureRoot creates root unless it exists.
+func ensureRoot(ctx context.Context, c Client, root Root) error {
+	roots, err := c.ListRoots(ctx)
+	if err != nil {
+		return err
+	}
+	for _, r := range roots {
+		if r.Name == root.Name {
+			return nil
+		}
+	}
+	return c.CreateRoot(ctx, root.Name, root.Description, root.IPv6)
+}
+
+func importNetwork(ctx context.Context, c Client, n Network, mode ImportMode, result *ImportResult) error {
+	existing, err := c.Get(ctx, n.Network)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		if err := c.Add(ctx, n.Network, n.Description, n.Tags); err != nil {
+			return err
+		}
+		result.Added++
+		return nil
+	case err != nil:
+		return err
+	}
+
+	switch {
+	case existing.Description == n.Description && sameTags(existing.Tags, n.Tags):
+		result.Skipped++
+	case mode == ImportSkipExisting:
+		result.Skipped++
+	case mode == ImportOverwrite:
+		if err := c.Update(ctx, n.Network, n.Description, n.Tags); err != nil {
+			return err
+		}
+		result.Updated++
+	default:
+		return newError(ErrAlreadyExists, "%s exists as %q with tags %q, exported as %q with tags %q",
+			n.Network, existing.Description, existing.Tags, n.Description, n.Tags)
+	}
+	return nil
+}
+
+// readJSONExport decodes an ExportJSON export, storing its root in root
+// and calling add for each network.
+func readJSONExport(r io.Reader, root *Root, add func(Network) error) error {
+	d := json.NewDecoder(r)
+	d.UseNumber()
+	if err := expectDelim(d, '{'); err != nil {
+		return err
+	}
+	for d.More() {
+		key, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch key {
+		case "root":
+			exported := Root{}
+			if err := d.Decode(&exported); err != nil {
+				return err
+			}
+			if exported.Name != "" {
+				*root = exported
+			}
+		case "networks":
+			if err := expectDelim(d, '['); err != nil {
+				return err
+			}
+			for d.More() {
+				var n Network
+				if err := d.Decode(&n); err != nil {
+					return err
+				}
+				if err := add(n); err != nil {
+					return err
+				}
+			}
+			if err := expectDelim(d, ']'); err != nil {
+				return err
+			}
+		default:
+			var skip json.RawMessage
+			if err := d.Decode(&skip); err != nil {
+				return err
+			}
+		}
+	}
+	return expectDelim(d, '}')
+}
+
+func expectDelim(d *json.Decoder, delim json.Delim) error {
+	t, err := d.Token()
+	if err != nil {
+		return err
+	}
+	if t != delim {
+		return fmt.Errorf("invalid export: got %v, want %v", t, delim)
+	}
+	return nil
+}
+
+// readCSVExport reads an ExportCSV export and calls add for each network.
+// Only the network, description and tags columns are used.
+func readCSVExport(r io.Reader, add func(Network) error) error {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err != nil {
+		return fmt.Errorf("invalid export: %s", err.Error())
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[name] = i
+	}
+	for _, name := range []string{"network", "description", "tags"} {
+		if _, ok := columns[name]; !ok {
+			return fmt.Errorf("invalid export: no %s column", name)
+		}
+	}
+
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		n := Network{Network: row[columns["network"]], Description: row[columns["description"]]}
+		if tags := row[columns["tags"]]; tags != "" {
+			if err := json.Unmarshal([]byte(tags), &n.Tags); err != nil {
+				return fmt.Errorf("invalid tags %q of %s: %s", tags, n.Network, err.Error())
+			}
+		}
+		if err := add(n); err != nil {
+			return err
+		}
+	}
+}
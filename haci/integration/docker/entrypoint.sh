@@ -0,0 +1,26 @@
+#!/bin/sh
+# Points HaCi at the database of compose.yaml, creates its tables on the
+# first start and runs Apache in the foreground.
+set -e
+
+conf=/opt/HaCi/etc/HaCi.conf
+sed -i \
+	-e "s/^\([[:space:]]*dbhost[[:space:]]*=\).*/\1 ${DB_HOST:-db}/" \
+	-e "s/^\([[:space:]]*dbname[[:space:]]*=\).*/\1 ${DB_NAME:-HaCi}/" \
+	-e "s/^\([[:space:]]*dbuser[[:space:]]*=\).*/\1 ${DB_USER:-HaCi}/" \
+	-e "s/^\([[:space:]]*dbpass[[:space:]]*=\).*/\1 ${DB_PASSWORD:-HaCi}/" \
+	"$conf"
+
+mysql="mariadb -h ${DB_HOST:-db} -u ${DB_USER:-HaCi} -p${DB_PASSWORD:-HaCi} ${DB_NAME:-HaCi}"
+until $mysql -e 'SELECT 1' >/dev/null 2>&1; do
+	sleep 1
+done
+
+# The schema and the admin user with password admin come with the release.
+if [ -z "$($mysql -N -e 'SHOW TABLES')" ]; then
+	for schema in $(find /opt/HaCi -name '*.sql' | sort); do
+		$mysql < "$schema"
+	done
+fi
+
+exec apache2ctl -D FOREGROUND
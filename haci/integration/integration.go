@@ -0,0 +1,213 @@
+//go:build integration
+
+// Package integration provides a real HaCi server for integration tests. It
+// is only built with the "integration" build tag.
+//
+// Without configuration, Start builds and starts HaCi with a MariaDB
+// database from docker/compose.yaml in this package's directory. The image
+// is built from a HaCi release, which has to be downloaded from
+// https://sourceforge.net/projects/haci/ and saved as docker/HaCi.tar.gz
+// first. Then run the suite with
+//
+//	go test -tags integration ./haci/integration/
+//
+// The server is configured by environment variables:
+//
+//	HACI_URL                      URL of a running HaCi server to use
+//	HACI_IMAGE                    Docker image to start instead of the
+//	                              compose project; it must serve HaCi on
+//	                              port 80 and bring its own database
+//	HACI_TARBALL                  name of the HaCi release in docker/,
+//	                              "HaCi.tar.gz" by default
+//	HACI_USERNAME, HACI_PASSWORD  credentials, "admin" and "admin" by default
+//	HACI_START_TIMEOUT            time to wait for HaCi, "5m" by default
+//
+// Tests using Start are skipped if none of HACI_URL, HACI_IMAGE and the
+// release is there, or if docker is not installed.
+package integration
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Nexinto/go-haci-client/haci"
+	"github.com/Nexinto/go-haci-client/haci/hacitest"
+)
+
+// T is the part of testing.TB used by the package.
+type T interface {
+	Helper()
+	Skipf(format string, args ...interface{})
+	Fatalf(format string, args ...interface{})
+	Cleanup(func())
+}
+
+// Server is a HaCi server for a test.
+type Server struct {
+	URL      string
+	Username string
+	Password string
+}
+
+// Start returns the server from HACI_URL, or starts HaCi from HACI_IMAGE
+// or docker/compose.yaml. The containers are removed when the test ends.
+func Start(t T) *Server {
+	t.Helper()
+
+	s := &Server{URL: os.Getenv("HACI_URL"), Username: getenv("HACI_USERNAME", "admin"), Password: getenv("HACI_PASSWORD", "admin")}
+	if s.URL != "" {
+		return s
+	}
+
+	if _, err := exec.LookPath("docker"); err != nil {
+		t.Skipf("HACI_URL is not set and docker is not installed")
+	}
+	timeout, err := time.ParseDuration(getenv("HACI_START_TIMEOUT", "5m"))
+	if err != nil {
+		t.Fatalf("invalid HACI_START_TIMEOUT: %s", err.Error())
+	}
+
+	var port string
+	var logs func() string
+	if image := os.Getenv("HACI_IMAGE"); image != "" {
+		id, err := docker("run", "--detach", "--publish", "127.0.0.1::80", image)
+		if err != nil {
+			t.Fatalf("starting HaCi: %s", err.Error())
+		}
+		t.Cleanup(func() { docker("rm", "--force", id) })
+
+		if port, err = docker("port", id, "80/tcp"); err != nil {
+			t.Fatalf("finding port of HaCi: %s", err.Error())
+		}
+		logs = func() string {
+			out, _ := docker("logs", "--tail", "50", id)
+			return out
+		}
+	} else {
+		port, logs = startCompose(t)
+	}
+	s.URL = "http://" + strings.Split(port, "\n")[0]
+
+	if err := s.wait(timeout); err != nil {
+		t.Fatalf("HaCi did not start: %s\n%s", err.Error(), logs())
+	}
+	return s
+}
+
+// startCompose starts the compose project of HaCi and its database and
+// returns the address of HaCi and a function returning its latest logs.
+// The project is removed when the test ends.
+func startCompose(t T) (port string, logs func() string) {
+	t.Helper()
+
+	_, file, _, _ := runtime.Caller(0)
+	dir := filepath.Join(filepath.Dir(file), "docker")
+	tarball := getenv("HACI_TARBALL", "HaCi.tar.gz")
+	if _, err := os.Stat(filepath.Join(dir, tarball)); err != nil {
+		t.Skipf("neither HACI_URL nor HACI_IMAGE is set, and there is no HaCi release in %s: %s", dir, err.Error())
+	}
+
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	compose := []string{"compose", "--file", filepath.Join(dir, "compose.yaml"), "--project-name", "haci-test-" + hex.EncodeToString(suffix)}
+	run := func(args ...string) (string, error) {
+		return docker(append(append([]string{}, compose...), args...)...)
+	}
+
+	t.Cleanup(func() { run("down", "--volumes") })
+	if _, err := run("up", "--detach", "--build"); err != nil {
+		t.Fatalf("starting HaCi: %s", err.Error())
+	}
+	port, err := run("port", "haci", "80")
+	if err != nil {
+		t.Fatalf("finding port of HaCi: %s", err.Error())
+	}
+	return port, func() string {
+		out, _ := run("logs", "--tail", "50")
+		return out
+	}
+}
+
+// RunClientTests runs hacitest.RunClientTests against WebClient and the
+// server from Start, each test in a new root. Call it from a test, e.g.
+//
+//	//go:build integration
+//
+//	func TestHaCi(t *testing.T) {
+//		integration.RunClientTests(t)
+//	}
+//
+// and run it with "go test -tags integration".
+func RunClientTests(t *testing.T) {
+	s := Start(t)
+	hacitest.RunClientTests(t, func(t *testing.T) haci.Client {
+		return s.Client(t)
+	})
+}
+
+// Client returns a client for a new, empty root on s. The root is deleted
+// when the test ends.
+func (s *Server) Client(t T, opts ...haci.Option) *haci.WebClient {
+	t.Helper()
+
+	ctx := context.Background()
+	c, err := s.client(opts...)
+	if err != nil {
+		t.Fatalf("creating client: %s", err.Error())
+	}
+
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	root := "test-" + hex.EncodeToString(suffix)
+	if err := c.CreateRoot(ctx, root, "integration test", false); err != nil {
+		t.Fatalf("creating root %s: %s", root, err.Error())
+	}
+	t.Cleanup(func() { c.DeleteRoot(ctx, root) })
+
+	return c.WithRoot(root)
+}
+
+func (s *Server) client(opts ...haci.Option) (*haci.WebClient, error) {
+	return haci.NewWebClient(s.URL, append([]haci.Option{haci.WithBasicAuth(s.Username, s.Password)}, opts...)...)
+}
+
+// wait waits until the server answers requests.
+func (s *Server) wait(timeout time.Duration) error {
+	c, err := s.client(haci.WithTimeout(5 * time.Second))
+	if err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		_, err := c.ListRoots(context.Background())
+		if err == nil || time.Now().After(deadline) {
+			return err
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+func docker(args ...string) (string, error) {
+	out, err := exec.Command("docker", args...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("docker %s: %s: %s", args[0], err.Error(), strings.TrimSpace(string(out)))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func getenv(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,25 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"testing"
+)
+
+func TestHaCi(t *testing.T) {
+	RunClientTests(t)
+}
+
+func TestClientRootIsEmpty(t *testing.T) {
+	s := Start(t)
+	c := s.Client(t)
+
+	networks, err := c.Search(context.Background(), "", false)
+	if err != nil {
+		t.Fatalf("Search: %s", err)
+	}
+	if len(networks) != 0 {
+		t.Errorf("new root has %d networks", len(networks))
+	}
+}
@@ -0,0 +1,108 @@
+package haci
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+)
+
+// ListIter calls fn for every subnet of supernet. The networks are decoded
+// one at a time while the response is read, so neither the response nor
+// the listing is ever held in memory as a whole. Iteration stops at the
+// first error, which is returned; fn can return ErrStopIteration to stop
+// early without an error.
+//
+// The request is not retried once fn was called.
+func (c *WebClient) ListIter(ctx context.Context, supernet string, fn func(Network) error) (err error) {
+	ctx, end := c.operation(ctx, "ListIter", "supernet", supernet)
+	defer end(&err)
+
+	req := &Request{
+		Method:   "GET",
+		Endpoint: "getSubnets",
+		Params: neturl.Values{
+			"rootName": {c.Root},
+			"supernet": {supernet},
+		},
+		Header: http.Header{},
+	}
+	resp, err := c.do(withStream(ctx, func(r io.Reader) error {
+		return stopped(decodeEach(r, fn))
+	}), req, nil)
+
+	if err != nil {
+		return err
+	}
+
+	if resp.Status() != 200 {
+		return c.responseError("list", resp)
+	}
+
+	return nil
+}
+
+type streamKey struct{}
+
+// withStream returns a context that makes send pass the body of a
+// successful response to consume instead of reading it into memory.
+func withStream(ctx context.Context, consume func(io.Reader) error) context.Context {
+	return context.WithValue(ctx, streamKey{}, consume)
+}
+
+func (c *FakeClient) ListIter(ctx context.Context, supernet string, fn func(Network) error) error {
+	networks, err := c.List(ctx, supernet)
+	if err != nil {
+		return err
+	}
+	for _, n := range networks {
+		if err := fn(n); err != nil {
+			return stopped(err)
+		}
+	}
+	return nil
+}
+
+// ErrStopIteration can be returned by the callback of ListIter to end the
+// iteration early.
+var ErrStopIteration = errors.New("stop iteration")
+
+func stopped(err error) error {
+	if errors.Is(err, ErrStopIteration) {
+		return nil
+	}
+	return err
+}
+
+// decodeEach decodes a JSON list of networks element by element.
+func decodeEach(r io.Reader, fn func(Network) error) error {
+	dec := json.NewDecoder(r)
+
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok == nil {
+		// null, an empty listing
+		return nil
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return fmt.Errorf("expected a list of networks, got %v", tok)
+	}
+
+	for dec.More() {
+		var n Network
+		if err := dec.Decode(&n); err != nil {
+			return err
+		}
+		if err := fn(n); err != nil {
+			return err
+		}
+	}
+
+	_, err = dec.Token()
+	return err
+}
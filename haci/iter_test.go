@@ -0,0 +1,83 @@
+package haci_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Nexinto/go-haci-client/haci"
+)
+
+// listingServer answers every request with a listing of n networks.
+func listingServer(b *testing.B, n int) *httptest.Server {
+	networks := make([]haci.Network, n)
+	for i := range networks {
+		networks[i] = haci.Network{
+			Network:     fmt.Sprintf("10.%d.%d.0/24", i/256%256, i%256),
+			Description: fmt.Sprintf("network %d", i),
+			Tags:        []string{"bench", "large"},
+			CreateDate:  "2024-05-01 10:00:00",
+			CreateFrom:  "admin",
+			ID:          json.Number(fmt.Sprint(i)),
+		}
+	}
+	body, err := json.Marshal(networks)
+	if err != nil {
+		b.Fatal(err)
+	}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	}))
+	b.Cleanup(s.Close)
+	b.SetBytes(int64(len(body)))
+	return s
+}
+
+func BenchmarkListIter(b *testing.B) {
+	ctx := context.Background()
+	s := listingServer(b, 50000)
+	c, err := haci.NewWebClient(s.URL, haci.WithRoot("bench"), haci.WithoutCompression())
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		count := 0
+		if err := c.ListIter(ctx, "10.0.0.0/8", func(haci.Network) error {
+			count++
+			return nil
+		}); err != nil {
+			b.Fatal(err)
+		}
+		if count != 50000 {
+			b.Fatalf("got %d networks, want 50000", count)
+		}
+	}
+}
+
+func BenchmarkList(b *testing.B) {
+	ctx := context.Background()
+	s := listingServer(b, 50000)
+	c, err := haci.NewWebClient(s.URL, haci.WithRoot("bench"), haci.WithoutCompression())
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		networks, err := c.List(ctx, "10.0.0.0/8")
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(networks) != 50000 {
+			b.Fatalf("got %d networks, want 50000", len(networks))
+		}
+	}
+}
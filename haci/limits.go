@@ -0,0 +1,92 @@
+package haci
+
+import "io"
+
+const (
+	// DefaultMaxResponseSize is the largest response body read unless
+	// changed with WithMaxResponseSize.
+	DefaultMaxResponseSize = 256 << 20
+
+	// DefaultMaxDepth is the deepest nesting of JSON arrays and objects
+	// accepted in responses unless changed with WithMaxDepth.
+	DefaultMaxDepth = 32
+)
+
+// WithMaxResponseSize limits the size of response bodies, after
+// decompression. Larger responses fail with an error matching
+// ErrLimitExceeded. Zero means no limit.
+func WithMaxResponseSize(bytes int64) Option {
+	return func(c *clientConfig) error {
+		c.maxResponseSize = bytes
+		return nil
+	}
+}
+
+// WithMaxDepth limits the nesting of JSON arrays and objects in responses.
+// Deeper responses fail with an error matching ErrLimitExceeded. Zero
+// means no limit.
+func WithMaxDepth(depth int) Option {
+	return func(c *clientConfig) error {
+		c.maxDepth = depth
+		return nil
+	}
+}
+
+// limitReader fails once more than remaining bytes are read or JSON
+// nesting gets deeper than maxDepth. Nesting is tracked across reads,
+// outside of strings.
+type limitReader struct {
+	r         io.Reader
+	limit     int64
+	remaining int64
+	maxDepth  int
+
+	depth    int
+	inString bool
+	escaped  bool
+}
+
+func newLimitReader(r io.Reader, limit int64, maxDepth int) io.Reader {
+	if limit <= 0 && maxDepth <= 0 {
+		return r
+	}
+	return &limitReader{r: r, limit: limit, remaining: limit, maxDepth: maxDepth}
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	if l.limit > 0 {
+		l.remaining -= int64(n)
+		if l.remaining < 0 {
+			return 0, newError(ErrLimitExceeded, "response larger than %d bytes", l.limit)
+		}
+	}
+	if l.maxDepth > 0 {
+		if err := l.scan(p[:n]); err != nil {
+			return 0, err
+		}
+	}
+	return n, err
+}
+
+func (l *limitReader) scan(p []byte) error {
+	for _, b := range p {
+		switch {
+		case l.escaped:
+			l.escaped = false
+		case l.inString && b == '\\':
+			l.escaped = true
+		case b == '"':
+			l.inString = !l.inString
+		case l.inString:
+		case b == '[' || b == '{':
+			l.depth++
+			if l.depth > l.maxDepth {
+				return newError(ErrLimitExceeded, "response nested deeper than %d levels", l.maxDepth)
+			}
+		case b == ']' || b == '}':
+			l.depth--
+		}
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package haci
+
+// Logger receives log messages from a WebClient. Arguments are alternating
+// keys and values, so a *slog.Logger can be used directly.
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+// WithLogger logs requests, retries, failovers and errors to logger.
+func WithLogger(logger Logger) Option {
+	return func(c *clientConfig) error {
+		c.logger = logger
+		return nil
+	}
+}
+
+// nopLogger discards all messages.
+type nopLogger struct{}
+
+func (nopLogger) Debug(msg string, args ...interface{}) {}
+func (nopLogger) Info(msg string, args ...interface{})  {}
+func (nopLogger) Warn(msg string, args ...interface{})  {}
+func (nopLogger) Error(msg string, args ...interface{}) {}
@@ -0,0 +1,67 @@
+package haci
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// WithPrometheus registers request counters and latency histograms with reg:
+//
+//	haci_requests_total{op,status}
+//	haci_request_duration_seconds{op}
+//
+// op is the RESTWrapper endpoint, status the HTTP status code or "error" if
+// no response was received. Several clients may share one registry.
+func WithPrometheus(reg prometheus.Registerer) Option {
+	return func(c *clientConfig) error {
+		m := &metrics{
+			requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+				Name: "haci_requests_total",
+				Help: "Number of HaCi requests by operation and status.",
+			}, []string{"op", "status"}),
+			duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+				Name:    "haci_request_duration_seconds",
+				Help:    "Latency of HaCi requests by operation.",
+				Buckets: prometheus.DefBuckets,
+			}, []string{"op"}),
+		}
+
+		if err := reg.Register(m.requests); err != nil {
+			existing := prometheus.AlreadyRegisteredError{}
+			if !errors.As(err, &existing) {
+				return err
+			}
+			m.requests = existing.ExistingCollector.(*prometheus.CounterVec)
+		}
+		if err := reg.Register(m.duration); err != nil {
+			existing := prometheus.AlreadyRegisteredError{}
+			if !errors.As(err, &existing) {
+				return err
+			}
+			m.duration = existing.ExistingCollector.(*prometheus.HistogramVec)
+		}
+
+		c.metrics = m
+		return nil
+	}
+}
+
+type metrics struct {
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+func (m *metrics) observe(op string, status int, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	label := "error"
+	if status != 0 {
+		label = strconv.Itoa(status)
+	}
+	m.requests.WithLabelValues(op, label).Inc()
+	m.duration.WithLabelValues(op).Observe(duration.Seconds())
+}
@@ -0,0 +1,97 @@
+package haci
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	neturl "net/url"
+)
+
+// Request is a call of a RESTWrapper endpoint as it passes through the
+// middleware chain.
+type Request struct {
+	Method   string
+	Endpoint string
+	Params   neturl.Values
+	// Header is sent in addition to the headers configured for the client
+	// and overrides them.
+	Header http.Header
+}
+
+// Response is the answer of HaCi to a Request.
+type Response struct {
+	Endpoint   string
+	StatusCode int
+	Header     http.Header
+	// Body is nil for listings that were decoded while being read, see
+	// ListIter.
+	Body []byte
+}
+
+// Status returns the HTTP status code.
+func (r *Response) Status() int { return r.StatusCode }
+
+// RawText returns the response body.
+func (r *Response) RawText() string { return string(r.Body) }
+
+// Doer sends requests to HaCi. A missing response with an error means the
+// request did not complete.
+type Doer interface {
+	Do(ctx context.Context, req *Request) (*Response, error)
+}
+
+// DoerFunc adapts a function to a Doer.
+type DoerFunc func(ctx context.Context, req *Request) (*Response, error)
+
+func (f DoerFunc) Do(ctx context.Context, req *Request) (*Response, error) { return f(ctx, req) }
+
+// Middleware wraps a Doer to add behavior to every request, e.g. signing,
+// extra headers or fault injection.
+type Middleware func(next Doer) Doer
+
+// WithMiddleware adds middleware to the chain requests pass through. The
+// first middleware given is the outermost. Middleware runs for every
+// attempt, inside retries, the circuit breaker, metrics and logging, and
+// after authentication, just before the request is sent to the server.
+//
+// Identical reads made at the same time share one request, which the
+// middleware sees with the context values of the call that started it.
+// Reads attributed to different callers with ContextWithCaller are never
+// shared.
+func WithMiddleware(mw ...Middleware) Option {
+	return func(c *clientConfig) error {
+		c.middleware = append(c.middleware, mw...)
+		return nil
+	}
+}
+
+// chain builds the middleware chain of the client. The built-in stages
+// are middleware themselves; from the outside in: tracing, metrics and
+// logging, the circuit breaker, rate limiting and retries,
+// authentication, the middleware of WithMiddleware and failover.
+func (c *WebClient) chain() Doer {
+	d := c.transport()
+	for _, mw := range []Middleware{c.authenticated, c.retried, c.guarded, c.observed} {
+		d = mw(d)
+	}
+	return d
+}
+
+// transport is the inner end of the chain: the middleware of
+// WithMiddleware around sending the request with failover.
+func (c *WebClient) transport() Doer {
+	var d Doer = DoerFunc(c.attempt)
+	if len(c.middleware) == 0 {
+		return d
+	}
+	for i := len(c.middleware) - 1; i >= 0; i-- {
+		d = c.middleware[i](d)
+	}
+	return DoerFunc(func(ctx context.Context, req *Request) (*Response, error) {
+		resp, err := d.Do(ctx, req)
+		if resp == nil && err == nil {
+			return nil, fmt.Errorf("middleware returned neither response nor error for %s", req.Endpoint)
+		}
+		return resp, err
+	})
+}
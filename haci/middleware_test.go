@@ -0,0 +1,103 @@
+package haci_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Nexinto/go-haci-client/haci"
+)
+
+func TestMiddlewareChain(t *testing.T) {
+	ctx := context.Background()
+	s := replay(t)
+
+	var calls []string
+	record := func(name string) haci.Middleware {
+		return func(next haci.Doer) haci.Doer {
+			return haci.DoerFunc(func(ctx context.Context, req *haci.Request) (*haci.Response, error) {
+				calls = append(calls, name+" "+req.Endpoint+" "+req.Header.Get("Authorization"))
+				req.Header.Set("X-"+name, "1")
+				return next.Do(ctx, req)
+			})
+		}
+	}
+	c, err := haci.NewWebClient(s.URL, haci.WithRoot("infra"), haci.WithBasicAuth("admin", "secret"),
+		haci.WithMiddleware(record("Outer"), record("Inner")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Get(ctx, "10.0.0.0/24"); err != nil {
+		t.Fatal(err)
+	}
+	s.servedOnly(t, "get")
+
+	// The middleware runs outermost first, after authentication.
+	auth := "Basic YWRtaW46c2VjcmV0"
+	want := []string{"Outer getNetworkDetails " + auth, "Inner getNetworkDetails " + auth}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("middleware calls %q, want %q", calls, want)
+	}
+	r := s.requests[len(s.requests)-1]
+	if r.Header.Get("X-Outer") != "1" || r.Header.Get("X-Inner") != "1" {
+		t.Errorf("headers of the middleware not sent: %v", r.Header)
+	}
+}
+
+func TestMiddlewareEveryAttempt(t *testing.T) {
+	ctx := context.Background()
+	s := replay(t)
+
+	attempts := 0
+	c, err := haci.NewWebClient(s.URL, haci.WithRoot("infra"),
+		haci.WithRetry(haci.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond, Multiplier: 1, RetryableStatus: []int{503}}),
+		haci.WithMiddleware(func(next haci.Doer) haci.Doer {
+			return haci.DoerFunc(func(ctx context.Context, req *haci.Request) (*haci.Response, error) {
+				attempts++
+				return next.Do(ctx, req)
+			})
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = c.List(ctx, "10.4.0.0/16")
+	s.servedOnly(t, "list-unavailable", "list-unavailable", "list-unavailable")
+	var apiErr *haci.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("got %v, want an APIError with status 503", err)
+	}
+	if attempts != 3 {
+		t.Errorf("middleware ran %d times, want 3", attempts)
+	}
+}
+
+func TestMiddlewareShortCircuit(t *testing.T) {
+	ctx := context.Background()
+	s := replay(t)
+
+	c, err := haci.NewWebClient(s.URL, haci.WithRoot("infra"), haci.WithMiddleware(func(next haci.Doer) haci.Doer {
+		return haci.DoerFunc(func(ctx context.Context, req *haci.Request) (*haci.Response, error) {
+			if req.Endpoint == "getRoots" {
+				return nil, nil
+			}
+			return &haci.Response{Endpoint: req.Endpoint, StatusCode: http.StatusNotFound, Body: []byte(`{"error":"network 10.0.0.0/24 not found"}`)}, nil
+		})
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Get(ctx, "10.0.0.0/24"); !errors.Is(err, haci.ErrNotFound) {
+		t.Errorf("got %v, want ErrNotFound from the middleware's response", err)
+	}
+	if _, err := c.ListRoots(ctx); err == nil || !strings.Contains(err.Error(), "neither response nor error") {
+		t.Errorf("got %v, want an error for a middleware without response", err)
+	}
+	s.servedOnly(t)
+}
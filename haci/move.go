@@ -0,0 +1,83 @@
+package haci
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Move moves network, and with recursive all networks below it, to
+// targetRoot, keeping descriptions and tags. The networks are first added
+// to targetRoot and then deleted from the root of c. HaCi has no
+// transactions: if adding fails, the networks added so far are removed
+// again; if deleting fails, the networks exist in both roots and the error
+// says which ones were not deleted and wraps the errors of deleting them.
+func (c *WebClient) Move(ctx context.Context, network, targetRoot string, recursive bool) (err error) {
+	ctx, end := c.operation(ctx, "Move", "network", network)
+	defer end(&err)
+
+	return move(ctx, c, c.WithRoot(targetRoot), network, recursive)
+}
+
+// Move moves network, and with recursive all networks below it, to the
+// fake of targetRoot returned by WithRoot, like Move of WebClient does.
+func (c *FakeClient) Move(ctx context.Context, network, targetRoot string, recursive bool) error {
+	if err := c.inject(ctx, "Move", network, targetRoot, recursive); err != nil {
+		return err
+	}
+
+	return move(ctx, c, c.WithRoot(targetRoot), network, recursive)
+}
+
+func move(ctx context.Context, from, to Client, network string, recursive bool) error {
+	top, err := from.Get(ctx, network)
+	if err != nil {
+		return err
+	}
+	networks := []Network{top}
+	if recursive {
+		descendants, err := from.ListRecursive(ctx, network)
+		if err != nil {
+			return err
+		}
+		networks = append(networks, descendants...)
+	}
+
+	// Add parents before their children, and delete in reverse order.
+	sort.SliceStable(networks, func(i, j int) bool {
+		return prefixLen(networks[i].Network) < prefixLen(networks[j].Network)
+	})
+
+	added := []Network{}
+	for _, n := range networks {
+		if err := to.Add(ctx, n.Network, n.Description, n.Tags); err != nil {
+			if rollbackErr := rollback(ctx, to, reversed(added)); rollbackErr != nil {
+				return fmt.Errorf("%w; rollback failed: %s", err, rollbackErr.Error())
+			}
+			return err
+		}
+		added = append(added, n)
+	}
+
+	var failed []string
+	var errs []error
+	for _, n := range reversed(networks) {
+		if err := from.Delete(ctx, n.Network); err != nil {
+			failed = append(failed, n.Network)
+			errs = append(errs, err)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("networks were copied, but could not delete %v from the source root: %w", failed, errors.Join(errs...))
+	}
+	return nil
+}
+
+func reversed(networks []Network) []Network {
+	r := make([]Network, len(networks))
+	for i, n := range networks {
+		r[len(networks)-1-i] = n
+	}
+	return r
+}
@@ -0,0 +1,93 @@
+package haci_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Nexinto/go-haci-client/haci"
+)
+
+func TestFakeMove(t *testing.T) {
+	ctx := context.Background()
+	setup := func(t *testing.T) (*haci.FakeClient, *haci.FakeClient) {
+		c := haci.NewFakeClient()
+		for _, n := range []string{"10.0.0.0/16", "10.0.1.0/24", "10.0.2.0/24"} {
+			if err := c.Add(ctx, n, "desc "+n, []string{"t"}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := c.CreateRoot(ctx, "target", "", false); err != nil {
+			t.Fatal(err)
+		}
+		return c, c.WithRoot("target")
+	}
+	exists := func(c *haci.FakeClient, network string) bool {
+		_, err := c.Get(ctx, network)
+		return err == nil
+	}
+
+	t.Run("Recursive", func(t *testing.T) {
+		c, target := setup(t)
+		if err := c.Move(ctx, "10.0.0.0/16", "target", true); err != nil {
+			t.Fatal(err)
+		}
+		c.AssertCalled(t, "Move", "10.0.0.0/16", "target", true)
+		for _, n := range []string{"10.0.0.0/16", "10.0.1.0/24", "10.0.2.0/24"} {
+			if exists(c, n) {
+				t.Errorf("%s is still in the source root", n)
+			}
+			got, err := target.Get(ctx, n)
+			if err != nil {
+				t.Errorf("%s is not in the target root: %s", n, err)
+			} else if got.Description != "desc "+n || len(got.Tags) != 1 || got.Tags[0] != "t" {
+				t.Errorf("%s was moved as %+v", n, got)
+			}
+		}
+	})
+
+	t.Run("Rollback", func(t *testing.T) {
+		c, target := setup(t)
+		if err := target.Add(ctx, "10.0.2.0/24", "taken", nil); err != nil {
+			t.Fatal(err)
+		}
+		err := c.Move(ctx, "10.0.0.0/16", "target", true)
+		if !errors.Is(err, haci.ErrAlreadyExists) {
+			t.Fatalf("got %v, want ErrAlreadyExists", err)
+		}
+		for _, n := range []string{"10.0.0.0/16", "10.0.1.0/24"} {
+			if !exists(c, n) {
+				t.Errorf("%s was deleted from the source root", n)
+			}
+			if exists(target, n) {
+				t.Errorf("%s was not removed from the target root", n)
+			}
+		}
+	})
+
+	t.Run("PartialDelete", func(t *testing.T) {
+		c, target := setup(t)
+		injected := errors.New("injected")
+		c.FailNext("Delete", 1, injected)
+		err := c.Move(ctx, "10.0.0.0/16", "target", true)
+		if !errors.Is(err, injected) {
+			t.Fatalf("got %v, want the injected error", err)
+		}
+		for _, n := range []string{"10.0.0.0/16", "10.0.1.0/24", "10.0.2.0/24"} {
+			if !exists(target, n) {
+				t.Errorf("%s is not in the target root", n)
+			}
+		}
+	})
+
+	t.Run("Injected", func(t *testing.T) {
+		c, target := setup(t)
+		c.FailNext("Move", 1, nil)
+		if err := c.Move(ctx, "10.0.1.0/24", "target", false); !errors.Is(err, haci.ErrUnreachable) {
+			t.Fatalf("got %v, want ErrUnreachable", err)
+		}
+		if !exists(c, "10.0.1.0/24") || exists(target, "10.0.1.0/24") {
+			t.Errorf("10.0.1.0/24 was moved")
+		}
+	})
+}
@@ -0,0 +1,131 @@
+package haci
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+
+	ccidr "github.com/apparentlymart/go-cidr/cidr"
+)
+
+// Contains reports whether the network contains addr, which is an IP
+// address or a network in CIDR notation.
+func (n Network) Contains(addr string) bool {
+	_, network, err := net.ParseCIDR(n.Network)
+	if err != nil {
+		return false
+	}
+	if ip := net.ParseIP(addr); ip != nil {
+		return network.Contains(ip)
+	}
+	return n.Network == addr || containsCIDR(n.Network, addr)
+}
+
+// PrefixLen returns the prefix length of the network, or -1 if it is not
+// a valid CIDR.
+func (n Network) PrefixLen() int {
+	return prefixLen(n.Network)
+}
+
+// FirstHost returns the first usable host address of the network. For IPv4
+// networks larger than a /31 this excludes the network address; for IPv6
+// networks larger than a /127 it excludes the subnet-router anycast address.
+func (n Network) FirstHost() (string, error) {
+	network, ones, bits, err := n.parse()
+	if err != nil {
+		return "", err
+	}
+	first, _ := ccidr.AddressRange(network)
+	if bits-ones > 1 {
+		first = ccidr.Inc(first)
+	}
+	return first.String(), nil
+}
+
+// LastHost returns the last usable host address of the network. For IPv4
+// networks larger than a /31 this excludes the broadcast address.
+func (n Network) LastHost() (string, error) {
+	network, ones, bits, err := n.parse()
+	if err != nil {
+		return "", err
+	}
+	_, last := ccidr.AddressRange(network)
+	if bits == 32 && bits-ones > 1 {
+		last = ccidr.Dec(last)
+	}
+	return last.String(), nil
+}
+
+// Broadcast returns the broadcast address of an IPv4 network.
+func (n Network) Broadcast() (string, error) {
+	network, _, bits, err := n.parse()
+	if err != nil {
+		return "", err
+	}
+	if bits != 32 {
+		return "", fmt.Errorf("IPv6 network %s has no broadcast address", n.Network)
+	}
+	_, last := ccidr.AddressRange(network)
+	return last.String(), nil
+}
+
+// HostCount returns the number of usable host addresses, the addresses
+// from FirstHost to LastHost.
+func (n Network) HostCount() (*big.Int, error) {
+	network, ones, bits, err := n.parse()
+	if err != nil {
+		return nil, err
+	}
+	count := addressCount(network)
+	switch {
+	case bits-ones <= 1:
+	case bits == 32:
+		count.Sub(count, big.NewInt(2))
+	default:
+		count.Sub(count, big.NewInt(1))
+	}
+	return count, nil
+}
+
+// Equal reports whether n and other describe the same network with the
+// same description and tags. CIDRs are compared in normalized form and
+// the order of tags is ignored; dates and other server-maintained fields
+// are not compared.
+func (n Network) Equal(other Network) bool {
+	return len(n.Diff(other)) == 0
+}
+
+// Diff lists the differences between n and other that Equal considers,
+// one line per differing field, e.g. `description: "old" -> "new"`.
+func (n Network) Diff(other Network) []string {
+	diff := []string{}
+	if a, b := normalizeCIDR(n.Network), normalizeCIDR(other.Network); a != b {
+		diff = append(diff, fmt.Sprintf("network: %s -> %s", a, b))
+	}
+	if n.Description != other.Description {
+		diff = append(diff, fmt.Sprintf("description: %q -> %q", n.Description, other.Description))
+	}
+	if !sameTags(n.Tags, other.Tags) {
+		diff = append(diff, fmt.Sprintf("tags: %q -> %q", n.Tags, other.Tags))
+	}
+	return diff
+}
+
+// normalizeCIDR returns cidr with the host bits cleared and the address in
+// canonical form, or cidr itself if it is invalid.
+func normalizeCIDR(cidr string) string {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return cidr
+	}
+	return network.String()
+}
+
+func (n Network) parse() (network *net.IPNet, ones, bits int, err error) {
+	_, network, err = net.ParseCIDR(n.Network)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	ones, bits = network.Mask.Size()
+	return
+}
@@ -0,0 +1,72 @@
+package haci
+
+import "sort"
+
+// Networks is a list of networks, as returned by List and Search, with
+// helpers for sorting and filtering.
+type Networks []Network
+
+// SortByCIDR sorts the networks by address and then by prefix length, in
+// place, and returns them.
+func (ns Networks) SortByCIDR() Networks {
+	sort.SliceStable(ns, func(i, j int) bool {
+		return compareCIDR(ns[i].Network, ns[j].Network) < 0
+	})
+	return ns
+}
+
+// FilterByTag returns the networks carrying tag.
+func (ns Networks) FilterByTag(tag string) Networks {
+	return ns.filter(func(n Network) bool {
+		return hasString(n.Tags, tag)
+	})
+}
+
+// FilterByPrefixLen returns the networks with the given prefix length.
+func (ns Networks) FilterByPrefixLen(length int) Networks {
+	return ns.filter(func(n Network) bool {
+		return n.PrefixLen() == length
+	})
+}
+
+// GroupBySupernet groups the networks by the most specific of supernets
+// containing them. Networks not contained in any of them are grouped
+// under the empty string.
+func (ns Networks) GroupBySupernet(supernets []string) map[string]Networks {
+	groups := map[string]Networks{}
+	for _, n := range ns {
+		group := ""
+		for _, s := range supernets {
+			if containsCIDR(s, n.Network) && (group == "" || prefixLen(s) > prefixLen(group)) {
+				group = s
+			}
+		}
+		groups[group] = append(groups[group], n)
+	}
+	return groups
+}
+
+func (ns Networks) filter(keep func(Network) bool) Networks {
+	filtered := Networks{}
+	for _, n := range ns {
+		if keep(n) {
+			filtered = append(filtered, n)
+		}
+	}
+	return filtered
+}
+
+// copyNetworks returns a copy of networks that shares no tags with them.
+func copyNetworks(networks []Network) []Network {
+	copied := make([]Network, len(networks))
+	for i, n := range networks {
+		copied[i] = copyNetwork(n)
+	}
+	return copied
+}
+
+// copyNetwork returns a copy of n that shares no tags with it.
+func copyNetwork(n Network) Network {
+	n.Tags = append([]string(nil), n.Tags...)
+	return n
+}
@@ -0,0 +1,329 @@
+package haci
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	neturl "net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Options controls which part of a result set ListWithOptions and
+// SearchWithOptions return. Hosts are networks with a full-length prefix
+// in HaCi and are listed like other subnets, so there is no separate
+// variant for them.
+//
+// The HaCi RESTWrapper has no filtering or sorting parameters, so these
+// options are applied client-side after the full result was fetched.
+// Paging is done by the server if it supports it, see WithServerPaging.
+type Options struct {
+	// Limit is the maximum number of networks returned. Zero means no limit.
+	Limit int
+	// Offset skips the first Offset networks of the filtered and sorted result.
+	Offset int
+	// Cursor continues a previous listing. It is taken from Page.Next and
+	// takes precedence over Offset.
+	Cursor string
+	// Fields restricts the returned networks to the named fields
+	// ("network", "description", "createDate", "createFrom", "tags", "ID",
+	// "state", "modifyDate", "modifyFrom", "defSubnetSize").
+	// The network field is always kept.
+	Fields []string
+	// Filters keeps only networks where the named field equals the value.
+	// The key "tag" keeps networks carrying that tag.
+	Filters map[string]string
+	// Sort orders the result by the named field, descending if prefixed with "-".
+	// Sorting by "network" compares addresses, not strings.
+	Sort string
+}
+
+// Page is a window of a result set.
+type Page struct {
+	Networks []Network
+	// Total is the number of networks matching the filters, before Offset
+	// and Limit. It is -1 if the server pages and the total is unknown.
+	Total int
+	// Next is the cursor for the following page; it is empty on the last page.
+	Next string
+}
+
+// offset returns the offset to start at, from the cursor if there is one.
+func (o Options) offset() (int, error) {
+	offset := o.Offset
+	if o.Cursor != "" {
+		start, _, _ := strings.Cut(o.Cursor, " ")
+		var err error
+		if offset, err = strconv.Atoi(start); err != nil || offset < 0 {
+			return 0, fmt.Errorf("invalid cursor %q", o.Cursor)
+		}
+	}
+	if offset < 0 || o.Limit < 0 {
+		return 0, fmt.Errorf("offset and limit must not be negative")
+	}
+	return offset, nil
+}
+
+// cursorNetwork returns the network a page fetched with server paging
+// must start with, if the cursor names one.
+func (o Options) cursorNetwork() string {
+	_, network, _ := strings.Cut(o.Cursor, " ")
+	return network
+}
+
+// pageable reports whether paging can be left to the server because no
+// filtering or sorting has to happen before it.
+func (o Options) pageable() bool {
+	return o.Limit > 0 && len(o.Filters) == 0 && o.Sort == ""
+}
+
+func pagingValues(offset, limit int) neturl.Values {
+	return neturl.Values{
+		"offset": {strconv.Itoa(offset)},
+		"limit":  {strconv.Itoa(limit)},
+	}
+}
+
+// serverPage fetches a page from a server that pages itself. fetch is
+// called with the paging parameters, or with nil for the full result.
+//
+// One network more than the page is requested. It tells whether there is
+// a next page, and the cursor of the next page names it, so a server that
+// ignored the paging parameters is recognized on every page. The page is
+// then taken from the full result client-side.
+func (o Options) serverPage(fetch func(paging neturl.Values) ([]Network, error)) (Page, error) {
+	offset, err := o.offset()
+	if err != nil {
+		return Page{}, err
+	}
+	networks, err := fetch(pagingValues(offset, o.Limit+1))
+	if err != nil {
+		return Page{}, err
+	}
+	if len(networks) > o.Limit+1 {
+		// The server ignored the paging parameters and sent everything.
+		return o.apply(networks)
+	}
+	if first := o.cursorNetwork(); first != "" && (len(networks) == 0 || networks[0].Network != first) {
+		// Either the server ignored the paging parameters, or the
+		// networks changed since the previous page.
+		if networks, err = fetch(nil); err != nil {
+			return Page{}, err
+		}
+		return o.apply(networks)
+	}
+
+	page := Page{Total: -1}
+	if len(networks) > o.Limit {
+		page.Next = strconv.Itoa(offset+o.Limit) + " " + networks[o.Limit].Network
+		networks = networks[:o.Limit]
+	}
+	for _, n := range networks {
+		n, err := selectFields(n, o.Fields)
+		if err != nil {
+			return Page{}, err
+		}
+		page.Networks = append(page.Networks, n)
+	}
+	return page, nil
+}
+
+// EachPage fetches all pages of a listing, starting at opts, and calls fn
+// for every page. fetch is usually a closure around ListWithOptions or
+// SearchWithOptions; opts.Limit sets the page size.
+func EachPage(ctx context.Context, opts Options, fetch func(context.Context, Options) (Page, error), fn func(Page) error) error {
+	for {
+		page, err := fetch(ctx, opts)
+		if err != nil {
+			return err
+		}
+		if err := fn(page); err != nil {
+			return err
+		}
+		if page.Next == "" {
+			return nil
+		}
+		opts.Cursor = page.Next
+	}
+}
+
+func (o Options) apply(networks []Network) (Page, error) {
+	offset, err := o.offset()
+	if err != nil {
+		return Page{}, err
+	}
+
+	filtered := []Network{}
+	for _, n := range networks {
+		ok, err := matchFilters(n, o.Filters)
+		if err != nil {
+			return Page{}, err
+		}
+		if ok {
+			filtered = append(filtered, n)
+		}
+	}
+
+	if o.Sort != "" {
+		field := strings.TrimPrefix(o.Sort, "-")
+		if _, ok := (Network{}).field(field); !ok {
+			return Page{}, fmt.Errorf("cannot sort by unknown field %q", field)
+		}
+		desc := strings.HasPrefix(o.Sort, "-")
+		sort.SliceStable(filtered, func(i, j int) bool {
+			if desc {
+				return lessField(filtered[j], filtered[i], field)
+			}
+			return lessField(filtered[i], filtered[j], field)
+		})
+	}
+
+	page := Page{Total: len(filtered)}
+	if offset > len(filtered) {
+		offset = len(filtered)
+	}
+	end := len(filtered)
+	if o.Limit > 0 && offset+o.Limit < end {
+		end = offset + o.Limit
+		page.Next = strconv.Itoa(end)
+	}
+
+	for _, n := range filtered[offset:end] {
+		n, err := selectFields(n, o.Fields)
+		if err != nil {
+			return Page{}, err
+		}
+		page.Networks = append(page.Networks, n)
+	}
+
+	return page, nil
+}
+
+// field returns the value of a string field by its JSON name.
+func (n Network) field(name string) (string, bool) {
+	switch name {
+	case "network":
+		return n.Network, true
+	case "description":
+		return n.Description, true
+	case "createDate":
+		return n.CreateDate, true
+	case "createFrom":
+		return n.CreateFrom, true
+	case "ID":
+		return n.ID.String(), true
+	case "state":
+		return n.State, true
+	case "modifyDate":
+		return n.ModifyDate, true
+	case "modifyFrom":
+		return n.ModifyFrom, true
+	case "defSubnetSize":
+		return n.DefSubnetSize.String(), true
+	}
+	return "", false
+}
+
+func matchFilters(n Network, filters map[string]string) (bool, error) {
+	for k, v := range filters {
+		if k == "tag" {
+			if !hasString(n.Tags, v) {
+				return false, nil
+			}
+			continue
+		}
+		value, ok := n.field(k)
+		if !ok {
+			return false, fmt.Errorf("cannot filter by unknown field %q", k)
+		}
+		if value != v {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func lessField(a, b Network, field string) bool {
+	if field == "network" {
+		return compareCIDR(a.Network, b.Network) < 0
+	}
+	x, _ := a.field(field)
+	y, _ := b.field(field)
+	return x < y
+}
+
+// compareCIDR orders networks by address and then by prefix length. Strings
+// that are not valid CIDRs sort after valid ones.
+func compareCIDR(a, b string) int {
+	ipa, na, erra := net.ParseCIDR(a)
+	ipb, nb, errb := net.ParseCIDR(b)
+	switch {
+	case erra != nil && errb != nil:
+		return strings.Compare(a, b)
+	case erra != nil:
+		return 1
+	case errb != nil:
+		return -1
+	}
+
+	if c := bytes.Compare(ipa.To16(), ipb.To16()); c != 0 {
+		return c
+	}
+	la, _ := na.Mask.Size()
+	lb, _ := nb.Mask.Size()
+	return la - lb
+}
+
+func selectFields(n Network, fields []string) (Network, error) {
+	if len(fields) == 0 {
+		return n, nil
+	}
+	selected := Network{Network: n.Network}
+	for _, f := range fields {
+		switch f {
+		case "network":
+		case "description":
+			selected.Description = n.Description
+		case "createDate":
+			selected.CreateDate = n.CreateDate
+		case "createFrom":
+			selected.CreateFrom = n.CreateFrom
+		case "tags":
+			selected.Tags = n.Tags
+		case "ID":
+			selected.ID = n.ID
+		case "state":
+			selected.State = n.State
+		case "modifyDate":
+			selected.ModifyDate = n.ModifyDate
+		case "modifyFrom":
+			selected.ModifyFrom = n.ModifyFrom
+		case "defSubnetSize":
+			selected.DefSubnetSize = n.DefSubnetSize
+		default:
+			return Network{}, fmt.Errorf("unknown field %q", f)
+		}
+	}
+	return selected, nil
+}
+
+func hasString(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// prefixLen returns the prefix length of a CIDR, or -1 if it is invalid.
+func prefixLen(cidr string) int {
+	_, n, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return -1
+	}
+	l, _ := n.Mask.Size()
+	return l
+}
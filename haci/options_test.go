@@ -0,0 +1,73 @@
+package haci_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/Nexinto/go-haci-client/haci"
+	"github.com/Nexinto/go-haci-client/haci/hacitest"
+)
+
+func TestServerPaging(t *testing.T) {
+	ctx := context.Background()
+	s := hacitest.NewServer("test")
+	defer s.Close()
+	if err := s.Fake("test").Add(ctx, "10.0.0.0/16", "", nil); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{}
+	for i := 0; i < 5; i++ {
+		n := fmt.Sprintf("10.0.%d.0/24", i)
+		if err := s.Fake("test").Add(ctx, n, "", nil); err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, n)
+	}
+
+	// ignorePaging makes the server send the full result, like HaCi
+	// versions without paging do.
+	ignorePaging := func(next haci.Doer) haci.Doer {
+		return haci.DoerFunc(func(ctx context.Context, req *haci.Request) (*haci.Response, error) {
+			req.Params.Del("offset")
+			req.Params.Del("limit")
+			return next.Do(ctx, req)
+		})
+	}
+	for name, opts := range map[string][]haci.Option{
+		"honoured": {haci.WithRoot("test"), haci.WithServerPaging()},
+		"ignored":  {haci.WithRoot("test"), haci.WithServerPaging(), haci.WithMiddleware(ignorePaging)},
+	} {
+		c, err := haci.NewWebClient(s.URL, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for limit := 1; limit <= 6; limit++ {
+			got := []string{}
+			pages := 0
+			err := haci.EachPage(ctx, haci.Options{Limit: limit}, func(ctx context.Context, o haci.Options) (haci.Page, error) {
+				if pages++; pages > 10 {
+					return haci.Page{}, errors.New("too many pages")
+				}
+				return c.ListWithOptions(ctx, "10.0.0.0/16", o)
+			}, func(p haci.Page) error {
+				if len(p.Networks) > limit {
+					return fmt.Errorf("page of %d networks", len(p.Networks))
+				}
+				for _, n := range p.Networks {
+					got = append(got, n.Network)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Errorf("%s, limit %d: %s", name, limit, err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%s, limit %d: got %v, want %v", name, limit, got, want)
+			}
+		}
+	}
+}
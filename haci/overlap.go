@@ -0,0 +1,89 @@
+package haci
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// OverlapError is returned by Add with WithOverlapCheck, or by
+// FakeClient.Add with OverlapCheck, if the network to be added overlaps
+// existing networks. It matches ErrOverlap.
+type OverlapError struct {
+	Network   string
+	Conflicts []Network
+}
+
+func (e *OverlapError) Error() string {
+	cidrs := []string{}
+	for _, n := range e.Conflicts {
+		cidrs = append(cidrs, n.Network)
+	}
+	return fmt.Sprintf("network %s overlaps %s", e.Network, strings.Join(cidrs, ", "))
+}
+
+func (e *OverlapError) Unwrap() error { return ErrOverlap }
+
+// Overlaps returns the networks in the root that overlap cidr: the networks
+// containing it, a network equal to it and the networks inside it.
+func (c *WebClient) Overlaps(ctx context.Context, cidr string) (networks []Network, err error) {
+	ctx, end := c.operation(ctx, "Overlaps", "network", cidr)
+	defer end(&err)
+
+	return overlaps(ctx, c, cidr)
+}
+
+func (c *FakeClient) Overlaps(ctx context.Context, cidr string) ([]Network, error) {
+	return overlaps(ctx, c, cidr)
+}
+
+func overlaps(ctx context.Context, c Client, cidr string) ([]Network, error) {
+	if _, _, err := net.ParseCIDR(cidr); err != nil {
+		return nil, err
+	}
+	all, err := c.Search(ctx, "", false)
+	if err != nil {
+		return nil, err
+	}
+	found := []Network{}
+	for _, n := range all {
+		if overlap(cidr, n.Network) {
+			found = append(found, n)
+		}
+	}
+	return Networks(found).SortByCIDR(), nil
+}
+
+// checkOverlap returns an OverlapError if cidr overlaps networks other than
+// the ones containing it. Nesting a network in a supernet is fine, but it
+// must neither exist already nor swallow existing networks.
+func checkOverlap(ctx context.Context, c Client, cidr string) error {
+	found, err := overlaps(ctx, c, cidr)
+	if err != nil {
+		return err
+	}
+	conflicts := []Network{}
+	for _, n := range found {
+		if !containsCIDR(n.Network, cidr) {
+			conflicts = append(conflicts, n)
+		}
+	}
+	if len(conflicts) > 0 {
+		return &OverlapError{Network: cidr, Conflicts: conflicts}
+	}
+	return nil
+}
+
+// overlap reports whether two CIDRs share any address.
+func overlap(a, b string) bool {
+	ipa, na, err := net.ParseCIDR(a)
+	if err != nil {
+		return false
+	}
+	ipb, nb, err := net.ParseCIDR(b)
+	if err != nil {
+		return false
+	}
+	return na.Contains(ipb.Mask(nb.Mask)) || nb.Contains(ipa.Mask(na.Mask))
+}
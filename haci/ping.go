@@ -0,0 +1,36 @@
+package haci
+
+import (
+	"context"
+	"errors"
+)
+
+// Ping checks that HaCi can be reached, accepts the credentials and has
+// the root of the client. It returns an error matching ErrUnreachable,
+// ErrUnauthorized or ErrRootNotFound if one of these fails. Use it for
+// readiness checks; it costs one listing of the roots.
+func (c *WebClient) Ping(ctx context.Context) (err error) {
+	ctx, end := c.operation(ctx, "Ping")
+	defer end(&err)
+
+	roots, err := c.ListRoots(ctx)
+	if err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) || errors.Is(err, ErrCircuitOpen) || ctx.Err() != nil {
+			return err
+		}
+		return newError(ErrUnreachable, "HaCi at %s unreachable: %s", c.URL, err.Error())
+	}
+
+	for _, r := range roots {
+		if r.Name == c.Root {
+			return nil
+		}
+	}
+	return newError(ErrRootNotFound, "root %s not found", c.Root)
+}
+
+// Ping succeeds for the fake unless a failure was injected with FailNext.
+func (c *FakeClient) Ping(ctx context.Context) error {
+	return c.inject(ctx, "Ping")
+}
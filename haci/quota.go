@@ -0,0 +1,65 @@
+package haci
+
+import (
+	"math/big"
+	"net"
+)
+
+// FakeQuota limits the subnets of a supernet in FakeClient, like an
+// administrator could.
+type FakeQuota struct {
+	// MaxNetworks is the number of subnets allowed, 0 for no limit.
+	MaxNetworks int
+
+	// MaxAddresses is the number of addresses allowed in all subnets
+	// together, nil for no limit.
+	MaxAddresses *big.Int
+}
+
+// SetQuota limits the subnets assigned from or added to supernet. Assign
+// and Add return an error matching ErrQuotaExceeded if a new subnet would
+// exceed the quota. Subnets that exist already are kept.
+func (c *FakeClient) SetQuota(supernet string, quota FakeQuota) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.quotas == nil {
+		c.quotas = map[string]FakeQuota{}
+	}
+	c.quotas[supernet] = quota
+}
+
+// checkQuota returns an error if adding network to supernet would exceed
+// the quota of supernet.
+func (c *FakeClient) checkQuota(supernet, network string) error {
+	q, ok := c.quotas[supernet]
+	if !ok {
+		return nil
+	}
+
+	existing := map[string]Network{}
+	if s, ok := c.Supernets[supernet]; ok {
+		existing = s.Networks
+	}
+
+	if q.MaxNetworks > 0 && len(existing)+1 > q.MaxNetworks {
+		return newError(ErrQuotaExceeded, "quota of %d networks in %s exceeded", q.MaxNetworks, supernet)
+	}
+
+	if q.MaxAddresses != nil {
+		used := big.NewInt(0)
+		count := func(cidr string) {
+			if _, n, err := net.ParseCIDR(cidr); err == nil {
+				used.Add(used, addressCount(n))
+			}
+		}
+		for cidr := range existing {
+			count(cidr)
+		}
+		count(network)
+		if used.Cmp(q.MaxAddresses) > 0 {
+			return newError(ErrQuotaExceeded, "quota of %s addresses in %s exceeded", q.MaxAddresses, supernet)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+package haci
+
+import (
+	"fmt"
+
+	"golang.org/x/time/rate"
+)
+
+// WithRateLimit throttles the client to requestsPerSecond requests on
+// average, allowing bursts of up to burst requests. Calls wait for their
+// turn or until their context is done.
+func WithRateLimit(requestsPerSecond float64, burst int) Option {
+	return func(c *clientConfig) error {
+		if requestsPerSecond <= 0 || burst < 1 {
+			return fmt.Errorf("rate limit needs a positive rate and burst")
+		}
+		c.limiter = rate.NewLimiter(rate.Limit(requestsPerSecond), burst)
+		return nil
+	}
+}
@@ -0,0 +1,53 @@
+package haci
+
+import (
+	"context"
+	neturl "net/url"
+)
+
+// Do calls any RESTWrapper endpoint, e.g. one this package doesn't wrap,
+// with the authentication, retries, middleware and error handling of the
+// client. rootName is set to the root of the client unless params has
+// it. The JSON response is decoded into out, if not nil.
+//
+// Only endpoints known to read data are sent as GET requests. Everything
+// else is sent like the mutations of the client, so read-only clients
+// refuse it and dry-run clients only record it.
+func (c *WebClient) Do(ctx context.Context, endpoint string, params neturl.Values, out interface{}) (err error) {
+	ctx, end := c.operation(ctx, "Do", "endpoint", endpoint)
+	defer end(&err)
+
+	values := neturl.Values{}
+	for k, v := range params {
+		values[k] = v
+	}
+	if _, ok := values["rootName"]; !ok {
+		values.Set("rootName", c.Root)
+	}
+
+	var resp *Response
+	if reading[endpoint] {
+		resp, err = c.get(ctx, endpoint, &values, out)
+	} else {
+		resp, err = c.post(ctx, endpoint, &values, out)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if resp.Status() != 200 {
+		return c.responseError(endpoint, resp)
+	}
+
+	return nil
+}
+
+// reading lists the RESTWrapper endpoints that only read data.
+var reading = map[string]bool{
+	"getVersion":        true,
+	"getRoots":          true,
+	"getNetworkDetails": true,
+	"getSubnets":        true,
+	"search":            true,
+}
@@ -0,0 +1,73 @@
+package haci_test
+
+import (
+	"context"
+	"errors"
+	neturl "net/url"
+	"testing"
+
+	"github.com/Nexinto/go-haci-client/haci"
+	"github.com/Nexinto/go-haci-client/haci/hacitest"
+)
+
+func TestDoReadOnly(t *testing.T) {
+	ctx := context.Background()
+	s := hacitest.NewServer("test")
+	defer s.Close()
+	if err := s.Fake("test").Add(ctx, "10.0.0.0/24", "existing", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := haci.NewWebClient(s.URL, haci.WithRoot("test"), haci.WithReadOnly())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var n haci.Network
+	if err := c.Do(ctx, "getNetworkDetails", neturl.Values{"network": {"10.0.0.0/24"}}, &n); err != nil {
+		t.Fatalf("reading: %s", err)
+	}
+	if n.Description != "existing" {
+		t.Errorf("got description %q, want existing", n.Description)
+	}
+
+	for _, endpoint := range []string{"delNet", "someNewEndpoint"} {
+		err := c.Do(ctx, endpoint, neturl.Values{"network": {"10.0.0.0/24"}}, nil)
+		if !errors.Is(err, haci.ErrReadOnly) {
+			t.Errorf("%s: got error %v, want one matching ErrReadOnly", endpoint, err)
+		}
+	}
+	for _, r := range s.Requests() {
+		if r.Endpoint != "getNetworkDetails" && r.Endpoint != "getVersion" {
+			t.Errorf("server received %s", r.Endpoint)
+		}
+	}
+}
+
+func TestDoDryRun(t *testing.T) {
+	ctx := context.Background()
+	s := hacitest.NewServer("test")
+	defer s.Close()
+	if err := s.Fake("test").Add(ctx, "10.0.0.0/24", "existing", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := haci.NewWebClient(s.URL, haci.WithRoot("test"), haci.WithDryRun())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Do(ctx, "delNet", neturl.Values{"network": {"10.0.0.0/24"}}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Do(ctx, "someNewEndpoint", neturl.Values{}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Fake("test").Get(ctx, "10.0.0.0/24"); err != nil {
+		t.Errorf("network was deleted: %s", err)
+	}
+	planned := c.PlannedChanges()
+	if len(planned) != 2 || planned[0].Op != "delete" || planned[0].Network != "10.0.0.0/24" || planned[1].Op != "someNewEndpoint" {
+		t.Errorf("got planned changes %+v", planned)
+	}
+}
@@ -0,0 +1,307 @@
+package haci
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// get sends a GET request to a RESTWrapper endpoint and decodes the JSON
+// response into result, if not nil. Concurrent identical requests share
+// one round trip, see coalesce.
+func (c *WebClient) get(ctx context.Context, endpoint string, params *neturl.Values, result interface{}) (*Response, error) {
+	val, _, err := c.coalesce(ctx, endpoint+"?"+params.Encode(), func(ctx context.Context) (interface{}, error) {
+		return c.do(ctx, &Request{Method: "GET", Endpoint: endpoint, Params: *params, Header: http.Header{}}, nil)
+	})
+	resp, _ := val.(*Response)
+	if err != nil {
+		return resp, err
+	}
+	return resp, decode(resp, result)
+}
+
+// getNetworks is get for listings of networks. The networks are decoded
+// one at a time while the response is read, so the body is never held in
+// memory as a whole. Callers sharing a round trip get their own copies.
+func (c *WebClient) getNetworks(ctx context.Context, endpoint string, params *neturl.Values) (*Response, []Network, error) {
+	type listing struct {
+		resp     *Response
+		networks []Network
+	}
+	val, shared, err := c.coalesce(ctx, "networks "+endpoint+"?"+params.Encode(), func(ctx context.Context) (interface{}, error) {
+		l := &listing{networks: []Network{}}
+		consume := func(r io.Reader) error {
+			return decodeEach(r, func(n Network) error {
+				l.networks = append(l.networks, n)
+				return nil
+			})
+		}
+		var err error
+		l.resp, err = c.do(withStream(ctx, consume), &Request{Method: "GET", Endpoint: endpoint, Params: *params, Header: http.Header{}}, nil)
+		return l, err
+	})
+	l, ok := val.(*listing)
+	if !ok {
+		return nil, nil, err
+	}
+	if err != nil || !shared {
+		return l.resp, l.networks, err
+	}
+	return l.resp, copyNetworks(l.networks), nil
+}
+
+// coalesce calls fn once for concurrent calls with the same key and hands
+// its result to all of them. The shared call runs with the context of the
+// call that started it, with its values but without its cancellation, so
+// it is not cancelled when that caller gives up; it is still bounded by
+// the client timeout. Calls attributed to different callers with
+// ContextWithCaller are not shared. Calls whose ctx overrides the timeout
+// or carries a span to trace the request under are not coalesced and run
+// fn with ctx.
+func (c *WebClient) coalesce(ctx context.Context, key string, fn func(ctx context.Context) (interface{}, error)) (val interface{}, shared bool, err error) {
+	if _, ok := ctx.Value(timeoutKey{}).(time.Duration); ok || c.tracer != nil && trace.SpanContextFromContext(ctx).IsValid() {
+		val, err = fn(ctx)
+		return val, false, err
+	}
+
+	if caller, ok := ctx.Value(callerKey{}).(string); ok {
+		key += "\x00" + caller
+	}
+	ch := c.flight.DoChan(key, func() (interface{}, error) {
+		return fn(context.WithoutCancel(ctx))
+	})
+	select {
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	case r := <-ch:
+		return r.Val, r.Shared, r.Err
+	}
+}
+
+// post sends the parameters of a mutating request as a form, or as a GET
+// query for HaCi versions that only accept GET. In dry-run mode the
+// request is only recorded and answered with an empty 200 response.
+func (c *WebClient) post(ctx context.Context, endpoint string, params *neturl.Values, result interface{}) (*Response, error) {
+	if c.readOnly {
+		return nil, newError(ErrReadOnly, "%s not allowed, the client is read-only", endpoint)
+	}
+	if c.planned != nil {
+		c.planRequest(endpoint, *params)
+		return &Response{Endpoint: endpoint, StatusCode: http.StatusOK, Header: http.Header{}}, nil
+	}
+	method := "POST"
+	if c.getMutations {
+		method = "GET"
+	} else if supported, err := c.supports(ctx, CapPOST); err != nil {
+		return nil, err
+	} else if !supported {
+		method = "GET"
+	}
+
+	start := time.Now()
+	req := &Request{Method: method, Endpoint: endpoint, Params: *params, Header: http.Header{}}
+	resp, err := c.do(ctx, req, result)
+	c.auditRequest(ctx, start, req, resp, err)
+	return resp, err
+}
+
+// do sends a request through the middleware chain and decodes the JSON
+// response into result, if not nil and the request succeeded.
+func (c *WebClient) do(ctx context.Context, req *Request, result interface{}) (*Response, error) {
+	resp, err := c.chain().Do(ctx, req)
+	if err != nil {
+		return resp, err
+	}
+	return resp, decode(resp, result)
+}
+
+// decode decodes the JSON body of a successful response into result, if
+// not nil.
+func decode(resp *Response, result interface{}) error {
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 || result == nil {
+		return nil
+	}
+	return json.Unmarshal(resp.Body, result)
+}
+
+// observed traces, measures and logs requests.
+func (c *WebClient) observed(next Doer) Doer {
+	return DoerFunc(func(ctx context.Context, req *Request) (*Response, error) {
+		ctx, span := c.startSpan(ctx, req.Endpoint, req.Params)
+
+		start := time.Now()
+		resp, err := next.Do(ctx, req)
+
+		status := 0
+		if resp != nil {
+			status = resp.Status()
+		}
+		c.metrics.observe(req.Endpoint, status, time.Since(start))
+		endSpan(span, status, err)
+
+		switch {
+		case err != nil:
+			c.log.Error("HaCi request failed", "endpoint", req.Endpoint, "root", c.Root, "duration", time.Since(start), "error", err)
+		case resp.Status() != 200:
+			c.log.Error("HaCi request failed", "endpoint", req.Endpoint, "root", c.Root, "duration", time.Since(start), "status", resp.Status())
+		default:
+			c.log.Debug("HaCi request", "endpoint", req.Endpoint, "root", c.Root, "duration", time.Since(start), "status", resp.Status())
+		}
+		return resp, err
+	})
+}
+
+// guarded fails requests while the circuit breaker is open.
+func (c *WebClient) guarded(next Doer) Doer {
+	if c.breaker == nil {
+		return next
+	}
+	return DoerFunc(func(ctx context.Context, req *Request) (*Response, error) {
+		ok, probe := c.breaker.allow()
+		if !ok {
+			return nil, ErrCircuitOpen
+		}
+		resp, err := next.Do(ctx, req)
+		if ctx.Err() != nil {
+			// A call cancelled by the caller says nothing about the server.
+			c.breaker.release(probe)
+		} else {
+			c.breaker.record(probe, resp == nil && err != nil || resp != nil && resp.Status() >= 500)
+		}
+		return resp, err
+	})
+}
+
+// retried rate limits requests and retries them according to the retry
+// policy.
+func (c *WebClient) retried(next Doer) Doer {
+	return DoerFunc(func(ctx context.Context, req *Request) (*Response, error) {
+		attempts := c.retry.attempts(req.Endpoint)
+		for attempt := 1; ; attempt++ {
+			if c.limiter != nil {
+				if err := c.limiter.Wait(ctx); err != nil {
+					return nil, err
+				}
+			}
+			resp, err := next.Do(ctx, req)
+			if attempt >= attempts || !c.retry.retryable(ctx, resp, err) {
+				return resp, err
+			}
+			wait := c.retry.backoff(attempt)
+			if err != nil {
+				c.log.Warn("retrying HaCi request", "endpoint", req.Endpoint, "attempt", attempt, "wait", wait, "error", err)
+			} else {
+				c.log.Warn("retrying HaCi request", "endpoint", req.Endpoint, "attempt", attempt, "wait", wait, "status", resp.Status())
+			}
+			if sleep(ctx, wait) != nil {
+				return resp, err
+			}
+		}
+	})
+}
+
+// attempt sends a request to the active server, failing over to the other
+// servers if it cannot be reached.
+func (c *WebClient) attempt(ctx context.Context, req *Request) (resp *Response, err error) {
+	for _, i := range c.endpoints.order() {
+		resp, err = c.send(ctx, c.endpoints.urls[i], req)
+		if resp == nil && err != nil && ctx.Err() == nil {
+			c.log.Warn("HaCi server unreachable, failing over", "url", c.endpoints.urls[i], "error", err)
+			c.endpoints.unreachable(i)
+			continue
+		}
+		c.endpoints.reached(i)
+		return
+	}
+	return
+}
+
+// send sends a request to the server at url. Each request is aborted when
+// ctx is cancelled, its deadline expires or the client timeout is reached.
+func (c *WebClient) send(ctx context.Context, url string, req *Request) (*Response, error) {
+	timeout := c.timeout
+	if t, ok := ctx.Value(timeoutKey{}).(time.Duration); ok {
+		timeout = t
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	// Parameters are sent percent-encoded as UTF-8, in the query for GET
+	// and as a form otherwise, so descriptions and tags may contain any
+	// character including &, # and non-ASCII ones.
+	var httpReq *http.Request
+	var err error
+	if req.Method == "GET" {
+		httpReq, err = http.NewRequest("GET", url+"/RESTWrapper/"+req.Endpoint+"?"+req.Params.Encode(), nil)
+	} else {
+		httpReq, err = http.NewRequest(req.Method, url+"/RESTWrapper/"+req.Endpoint, strings.NewReader(req.Params.Encode()))
+	}
+	if err != nil {
+		return nil, err
+	}
+	httpReq = httpReq.WithContext(ctx)
+	for k, v := range c.header {
+		httpReq.Header[k] = v
+	}
+	for k, v := range req.Header {
+		httpReq.Header[k] = v
+	}
+	httpReq.Header.Set("Accept", "application/json")
+	if req.Method != "GET" {
+		httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=UTF-8")
+	}
+
+	// Compression is handled here rather than by http.Transport so that it
+	// works with any transport set with WithTransport.
+	if !c.noGzip {
+		httpReq.Header.Set("Accept-Encoding", "gzip")
+	}
+
+	httpResp, err := c.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+
+	var reader io.Reader = httpResp.Body
+	if strings.EqualFold(httpResp.Header.Get("Content-Encoding"), "gzip") {
+		gz, err := gzip.NewReader(httpResp.Body)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		reader = gz
+		httpResp.Header.Del("Content-Encoding")
+		httpResp.Header.Del("Content-Length")
+	}
+
+	reader = newLimitReader(reader, c.maxSize, c.maxDepth)
+
+	if consume, ok := ctx.Value(streamKey{}).(func(io.Reader) error); ok && httpResp.StatusCode >= 200 && httpResp.StatusCode < 300 {
+		resp := &Response{Endpoint: req.Endpoint, StatusCode: httpResp.StatusCode, Header: httpResp.Header}
+		return resp, consume(reader)
+	}
+
+	body, err := ioutil.ReadAll(reader)
+	if errors.Is(err, ErrLimitExceeded) {
+		// The server answered, so there is no point in failing over.
+		return &Response{Endpoint: req.Endpoint, StatusCode: httpResp.StatusCode, Header: httpResp.Header}, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Response{Endpoint: req.Endpoint, StatusCode: httpResp.StatusCode, Header: httpResp.Header, Body: body}, nil
+}
@@ -0,0 +1,387 @@
+package haci_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Nexinto/go-haci-client/haci"
+	"github.com/Nexinto/go-haci-client/haci/hacitest"
+)
+
+// fixture is a request to HaCi and its response as recorded in
+// testdata/recorded.json.
+type fixture struct {
+	Name    string `json:"name"`
+	Request struct {
+		Method   string     `json:"method"`
+		Endpoint string     `json:"endpoint"`
+		Params   url.Values `json:"params"`
+	} `json:"request"`
+	Response struct {
+		Status int               `json:"status"`
+		Header map[string]string `json:"header"`
+		JSON   json.RawMessage   `json:"json"`
+		Text   string            `json:"text"`
+	} `json:"response"`
+}
+
+// recording is a server that answers the recorded requests and fails the
+// test on any other request.
+type recording struct {
+	*httptest.Server
+	fixtures map[string]fixture
+
+	mu       sync.Mutex
+	served   []string
+	requests []*http.Request
+}
+
+func replay(t *testing.T) *recording {
+	t.Helper()
+	data, err := os.ReadFile("testdata/recorded.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fixtures []fixture
+	if err := json.Unmarshal(data, &fixtures); err != nil {
+		t.Fatalf("invalid fixtures: %s", err)
+	}
+
+	s := &recording{fixtures: map[string]fixture{}}
+	for _, f := range fixtures {
+		s.fixtures[f.Name] = f
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("invalid request: %s", err)
+		}
+		s.mu.Lock()
+		s.requests = append(s.requests, r)
+		s.mu.Unlock()
+
+		f, ok := s.match(r)
+		if !ok {
+			t.Errorf("unrecorded request %s %s %v", r.Method, r.URL.Path, r.Form)
+			http.Error(w, "unrecorded request", http.StatusInternalServerError)
+			return
+		}
+		if accept := r.Header.Get("Accept"); accept != "application/json" {
+			t.Errorf("%s: Accept is %q, want application/json", f.Name, accept)
+		}
+		if r.Method == "POST" {
+			if ct := r.Header.Get("Content-Type"); !strings.HasPrefix(ct, "application/x-www-form-urlencoded") {
+				t.Errorf("%s: Content-Type is %q, want a form", f.Name, ct)
+			}
+			if r.URL.RawQuery != "" {
+				t.Errorf("%s: POST with query %q", f.Name, r.URL.RawQuery)
+			}
+		}
+
+		s.mu.Lock()
+		s.served = append(s.served, f.Name)
+		s.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		for k, v := range f.Response.Header {
+			w.Header().Set(k, v)
+		}
+		w.WriteHeader(f.Response.Status)
+		if f.Response.JSON != nil {
+			w.Write(f.Response.JSON)
+		} else {
+			w.Write([]byte(f.Response.Text))
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// match returns the fixture recorded for r.
+func (s *recording) match(r *http.Request) (fixture, bool) {
+	endpoint := strings.TrimPrefix(r.URL.Path, "/RESTWrapper/")
+	for _, f := range s.fixtures {
+		if f.Request.Method != r.Method || f.Request.Endpoint != endpoint {
+			continue
+		}
+		if len(f.Request.Params) == 0 && len(r.Form) == 0 || reflect.DeepEqual(f.Request.Params, r.Form) {
+			return f, true
+		}
+	}
+	return fixture{}, false
+}
+
+// servedOnly fails the test unless exactly the fixtures named were served
+// since the last call.
+func (s *recording) servedOnly(t *testing.T, names ...string) {
+	t.Helper()
+	s.mu.Lock()
+	served := s.served
+	s.served = nil
+	s.mu.Unlock()
+	if !reflect.DeepEqual(served, names) {
+		t.Errorf("served %q, want %q", served, names)
+	}
+}
+
+// sameJSON fails the test unless got encodes to the same JSON as want.
+func sameJSON(t *testing.T, name string, got interface{}, want json.RawMessage) {
+	t.Helper()
+	data, err := json.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var g, w interface{}
+	json.Unmarshal(data, &g)
+	json.Unmarshal(want, &w)
+	if !reflect.DeepEqual(g, w) {
+		t.Errorf("%s: got %s, want %s", name, data, want)
+	}
+}
+
+func TestRecordedRequests(t *testing.T) {
+	ctx := context.Background()
+	s := replay(t)
+	c, err := haci.NewWebClient(s.URL, haci.WithRoot("infra"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		fixture string
+		call    func() (interface{}, error)
+	}{
+		{"get", func() (interface{}, error) { return c.Get(ctx, "10.0.0.0/24") }},
+		{"list", func() (interface{}, error) { return c.List(ctx, "10.0.0.0/16") }},
+		{"search", func() (interface{}, error) { return c.Search(ctx, "web", false) }},
+		{"roots", func() (interface{}, error) { return c.ListRoots(ctx) }},
+		{"assign", func() (interface{}, error) {
+			return c.Assign(ctx, "10.0.0.0/16", "web & db #1", 24, []string{"web", "prod"})
+		}},
+		{"add", func() (interface{}, error) { return nil, c.Add(ctx, "10.0.5.0/24", "Büro 100%", nil) }},
+		{"update", func() (interface{}, error) {
+			return nil, c.Update(ctx, "10.0.5.0/24", "office", []string{"a+b", "c=d"})
+		}},
+		{"delete", func() (interface{}, error) { return nil, c.Delete(ctx, "10.0.5.0/24") }},
+	} {
+		result, err := test.call()
+		if err != nil {
+			t.Errorf("%s: %s", test.fixture, err)
+			continue
+		}
+		s.servedOnly(t, test.fixture)
+		if result != nil {
+			sameJSON(t, test.fixture, result, s.fixtures[test.fixture].Response.JSON)
+		}
+	}
+}
+
+func TestRecordedErrors(t *testing.T) {
+	ctx := context.Background()
+	s := replay(t)
+	c, err := haci.NewWebClient(s.URL, haci.WithRoot("infra"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kinds := []error{haci.ErrNotFound, haci.ErrAlreadyExists, haci.ErrNoFreeSubnet, haci.ErrUnauthorized}
+	for _, test := range []struct {
+		fixture string
+		call    func() error
+		op      string
+		message string
+		code    string
+		kind    error
+	}{
+		{"get-missing", func() error { _, err := c.Get(ctx, "10.9.0.0/24"); return err },
+			"lookup", "network 10.9.0.0/24 not found", "", haci.ErrNotFound},
+		{"add-exists", func() error { return c.Add(ctx, "10.0.0.0/24", "web", nil) },
+			"assignment", "network 10.0.0.0/24 already exists", "17", haci.ErrAlreadyExists},
+		{"assign-full", func() error { _, err := c.Assign(ctx, "10.1.0.0/24", "full", 25, nil); return err },
+			"assignment", "no free subnet of size /25 in 10.1.0.0/24", "NO_FREE_SUBNET", haci.ErrNoFreeSubnet},
+		{"list-unauthorized", func() error { _, err := c.List(ctx, "10.2.0.0/16"); return err },
+			"list", "authentication required", "", haci.ErrUnauthorized},
+		{"delete-failed", func() error { return c.Delete(ctx, "10.3.0.0/24") },
+			"delete", "<h1>Internal Server Error</h1>", "", nil},
+		{"get-missing-text", func() error { _, err := c.Get(ctx, "10.7.0.0/24"); return err },
+			"lookup", "Network 10.7.0.0/24 doesn't exist", "", haci.ErrNotFound},
+		// A 404 that is not from HaCi says nothing about the network.
+		{"get-proxy-404", func() error { _, err := c.Get(ctx, "10.5.0.0/24"); return err },
+			"lookup", "<html><head><title>404 Not Found</title></head><body><h1>Not Found</h1></body></html>", "", nil},
+		{"get-plain-404", func() error { _, err := c.Get(ctx, "10.6.0.0/24"); return err },
+			"lookup", "404 page not found\n", "", nil},
+		{"version-unknown", func() error { _, err := c.Version(ctx); return err },
+			"version", "unknown function getVersion", "", nil},
+	} {
+		err := test.call()
+		s.servedOnly(t, test.fixture)
+
+		var apiErr *haci.APIError
+		if !errors.As(err, &apiErr) {
+			t.Errorf("%s: got %v, want an APIError", test.fixture, err)
+			continue
+		}
+		want := s.fixtures[test.fixture]
+		if apiErr.Op != test.op || apiErr.StatusCode != want.Response.Status || apiErr.Message != test.message ||
+			apiErr.Code != test.code || apiErr.Endpoint != want.Request.Endpoint || apiErr.Root != "infra" {
+			t.Errorf("%s: got %+v", test.fixture, *apiErr)
+		}
+		for _, kind := range kinds {
+			if errors.Is(err, kind) != (kind == test.kind) {
+				t.Errorf("%s: errors.Is(%v) is %t", test.fixture, kind, !(kind == test.kind))
+			}
+		}
+	}
+}
+
+func TestSpecialCharactersRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	descriptions := []string{"web & db", "rack #4", "a=b&c=d", "Grüße aus Köln", "100% ok?", "🚀 launch", "+plus+ /slash/"}
+	tags := []string{"r&d", "#1", "größe", "🔥", "a=b", "x+y"}
+
+	for name, opts := range map[string][]haci.Option{
+		"POST": nil,
+		"GET":  {haci.WithGETMutations()},
+	} {
+		s := hacitest.NewServer("test")
+		defer s.Close()
+		c, err := haci.NewWebClient(s.URL, append([]haci.Option{haci.WithRoot("test")}, opts...)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Add(ctx, "10.0.0.0/16", "supernet", nil); err != nil {
+			t.Fatal(err)
+		}
+
+		for i, description := range descriptions {
+			network := fmt.Sprintf("10.1.%d.0/24", i)
+			if err := c.Add(ctx, network, description, tags); err != nil {
+				t.Fatalf("%s: Add %q: %s", name, description, err)
+			}
+			got, err := c.Get(ctx, network)
+			if err != nil {
+				t.Fatalf("%s: Get %s: %s", name, network, err)
+			}
+			if got.Description != description || !reflect.DeepEqual(got.Tags, tags) {
+				t.Errorf("%s: Add %q %q came back as %q %q", name, description, tags, got.Description, got.Tags)
+			}
+
+			assigned, err := c.Assign(ctx, "10.0.0.0/16", description, 28, tags)
+			if err != nil {
+				t.Fatalf("%s: Assign %q: %s", name, description, err)
+			}
+			if assigned.Description != description || !reflect.DeepEqual(assigned.Tags, tags) {
+				t.Errorf("%s: Assign %q %q returned %q %q", name, description, tags, assigned.Description, assigned.Tags)
+			}
+			got, err = c.Get(ctx, assigned.Network)
+			if err != nil {
+				t.Fatalf("%s: Get %s: %s", name, assigned.Network, err)
+			}
+			if got.Description != description || !reflect.DeepEqual(got.Tags, tags) {
+				t.Errorf("%s: Assign %q %q came back as %q %q", name, description, tags, got.Description, got.Tags)
+			}
+		}
+
+		for _, r := range s.Requests() {
+			if (r.Endpoint == "addNet" || r.Endpoint == "assignFreeSubnet") && r.Method != name {
+				t.Errorf("%s: %s sent as %s", name, r.Endpoint, r.Method)
+			}
+		}
+	}
+}
+
+type valueKey struct{}
+
+func TestCoalesce(t *testing.T) {
+	ctx := context.Background()
+	s := hacitest.NewServer("test")
+	defer s.Close()
+	if err := s.Fake("test").Add(ctx, "10.0.0.0/24", "web", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	entered := make(chan interface{}, 10)
+	release := make(chan struct{})
+	c, err := haci.NewWebClient(s.URL, haci.WithRoot("test"), haci.WithMiddleware(func(next haci.Doer) haci.Doer {
+		return haci.DoerFunc(func(ctx context.Context, req *haci.Request) (*haci.Response, error) {
+			entered <- ctx.Value(valueKey{})
+			<-release
+			return next.Do(ctx, req)
+		})
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func(ctx context.Context, wg *sync.WaitGroup) {
+		defer wg.Done()
+		if _, err := c.Get(ctx, "10.0.0.0/24"); err != nil {
+			t.Error(err)
+		}
+	}
+	wait := func() interface{} {
+		select {
+		case v := <-entered:
+			return v
+		case <-time.After(5 * time.Second):
+			t.Fatal("no request sent")
+			return nil
+		}
+	}
+
+	// Identical calls share one request, which sees the values of the
+	// call that started it.
+	var wg sync.WaitGroup
+	wg.Add(2)
+	before := len(s.Requests())
+	go get(context.WithValue(ctx, valueKey{}, "first"), &wg)
+	if v := wait(); v != "first" {
+		t.Errorf("shared request sees value %v, want first", v)
+	}
+	go get(context.WithValue(ctx, valueKey{}, "second"), &wg)
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if n := len(s.Requests()) - before; n != 1 {
+		t.Errorf("sent %d requests, want 1", n)
+	}
+
+	// A call with its own timeout is not coalesced.
+	release = make(chan struct{})
+	wg.Add(2)
+	before = len(s.Requests())
+	go get(haci.ContextWithTimeout(context.WithValue(ctx, valueKey{}, "own"), time.Hour), &wg)
+	if v := wait(); v != "own" {
+		t.Errorf("request with its own timeout sees value %v, want own", v)
+	}
+	go get(ctx, &wg)
+	wait()
+	close(release)
+	wg.Wait()
+	if n := len(s.Requests()) - before; n != 2 {
+		t.Errorf("sent %d requests, want 2", n)
+	}
+
+	// Calls of different callers are not shared.
+	release = make(chan struct{})
+	wg.Add(2)
+	before = len(s.Requests())
+	go get(haci.ContextWithCaller(ctx, "alice"), &wg)
+	wait()
+	go get(haci.ContextWithCaller(ctx, "bob"), &wg)
+	wait()
+	close(release)
+	wg.Wait()
+	if n := len(s.Requests()) - before; n != 2 {
+		t.Errorf("sent %d requests for two callers, want 2", n)
+	}
+}
@@ -0,0 +1,113 @@
+package haci
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy controls how WebClient retries requests that failed because of
+// connection errors or transient server errors.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts including the first one.
+	// Values below 2 disable retries.
+	MaxAttempts int
+
+	// InitialBackoff is the wait before the first retry. It grows by
+	// Multiplier after every attempt but never exceeds MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+
+	// Jitter randomizes each wait by up to this fraction (0 to 1) in either direction.
+	Jitter float64
+
+	// RetryableStatus lists the HTTP status codes that are retried.
+	RetryableStatus []int
+
+	// RetryMutations enables retries for Assign, Add, Update and Delete.
+	// They are not retried by default because a request that failed on the
+	// way back may still have changed data on the server.
+	RetryMutations bool
+}
+
+// DefaultRetryPolicy retries reads up to three times on connection errors
+// and gateway errors.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:     3,
+	InitialBackoff:  500 * time.Millisecond,
+	MaxBackoff:      10 * time.Second,
+	Multiplier:      2,
+	Jitter:          0.2,
+	RetryableStatus: []int{502, 503, 504},
+}
+
+// WithRetry enables retries according to policy.
+func WithRetry(policy RetryPolicy) Option {
+	return func(c *clientConfig) error {
+		c.retry = policy
+		return nil
+	}
+}
+
+// mutating lists the RESTWrapper endpoints that change data.
+var mutating = map[string]bool{
+	"assignFreeSubnet": true,
+	"addNet":           true,
+	"delNet":           true,
+	"editNet":          true,
+	"addRoot":          true,
+	"delRoot":          true,
+}
+
+func (p RetryPolicy) attempts(endpoint string) int {
+	if p.MaxAttempts < 2 || mutating[endpoint] && !p.RetryMutations {
+		return 1
+	}
+	return p.MaxAttempts
+}
+
+// retryable reports whether a failed attempt should be repeated. A missing
+// response means the request did not complete, e.g. because the connection
+// was refused or reset.
+func (p RetryPolicy) retryable(ctx context.Context, resp *Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if resp == nil {
+		return err != nil
+	}
+	for _, status := range p.RetryableStatus {
+		if resp.Status() == status {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns the wait before the given retry, starting with 1.
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	wait := float64(p.InitialBackoff)
+	for i := 1; i < retry; i++ {
+		wait *= p.Multiplier
+	}
+	if p.MaxBackoff > 0 && wait > float64(p.MaxBackoff) {
+		wait = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		wait += wait * p.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(wait)
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
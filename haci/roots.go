@@ -0,0 +1,147 @@
+package haci
+
+import (
+	"context"
+	"net/http"
+	neturl "net/url"
+	"sort"
+)
+
+// Root is a HaCi root, a separate tree of networks.
+type Root struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	IPv6        bool   `json:"ipv6"`
+}
+
+func (c *WebClient) ListRoots(ctx context.Context) (roots []Root, err error) {
+	ctx, end := c.operation(ctx, "ListRoots")
+	defer end(&err)
+
+	resp, err := c.get(ctx, "getRoots", &neturl.Values{}, &roots)
+
+	if err != nil {
+		return []Root{}, err
+	}
+
+	if resp.Status() != 200 {
+		return []Root{}, c.responseError("listing roots", resp)
+	}
+
+	return
+}
+
+func (c *WebClient) CreateRoot(ctx context.Context, name, description string, ipv6 bool) (err error) {
+	ctx, end := c.operation(ctx, "CreateRoot", "root", name)
+	defer end(&err)
+
+	values := neturl.Values{
+		"rootName":    {name},
+		"description": {description},
+	}
+	if ipv6 {
+		values["ipv6"] = []string{"1"}
+	}
+	resp, err := c.post(ctx, "addRoot", &values, nil)
+
+	if err != nil {
+		return err
+	}
+
+	if resp.Status() != 200 {
+		return c.responseError("creating root", resp)
+	}
+
+	return nil
+}
+
+// DeleteRoot deletes a root including all its networks.
+func (c *WebClient) DeleteRoot(ctx context.Context, name string) (err error) {
+	ctx, end := c.operation(ctx, "DeleteRoot", "root", name)
+	defer end(&err)
+
+	resp, err := c.post(ctx, "delRoot", &neturl.Values{"rootName": {name}}, nil)
+
+	if err != nil {
+		return err
+	}
+
+	if resp.Status() != 200 {
+		return c.responseError("deleting root", resp)
+	}
+
+	return nil
+}
+
+func (c *FakeClient) ListRoots(ctx context.Context) (roots []Root, err error) {
+	if err := c.inject(ctx, "ListRoots"); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.listRoots(), nil
+}
+
+func (c *FakeClient) listRoots() (roots []Root) {
+	for _, r := range c.Roots {
+		roots = append(roots, r)
+	}
+	sort.Slice(roots, func(i, j int) bool { return roots[i].Name < roots[j].Name })
+	return
+}
+
+func (c *FakeClient) CreateRoot(ctx context.Context, name, description string, ipv6 bool) error {
+	if err := c.inject(ctx, "CreateRoot", name, description, ipv6); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, exists := c.Roots[name]; exists {
+		return fakeError("creating root", "addRoot", http.StatusConflict, "root %s already exists", name)
+	}
+	if c.Roots == nil {
+		c.Roots = map[string]Root{}
+	}
+	c.Roots[name] = Root{Name: name, Description: description, IPv6: ipv6}
+	return nil
+}
+
+func (c *FakeClient) DeleteRoot(ctx context.Context, name string) error {
+	if err := c.inject(ctx, "DeleteRoot", name); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, exists := c.Roots[name]; !exists {
+		return fakeError("deleting root", "delRoot", http.StatusNotFound, "root %s not found", name)
+	}
+	delete(c.Roots, name)
+	delete(c.roots, name)
+	return nil
+}
+
+// WithRoot returns the fake holding the networks of another root, creating
+// it with the settings of c on first use. The networks of the fake itself
+// are those of its own, unnamed root. Move moves networks into the fakes
+// returned by WithRoot, and DeleteRoot removes them.
+func (c *FakeClient) WithRoot(root string) *FakeClient {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if f, ok := c.roots[root]; ok {
+		return f
+	}
+	f := NewFakeClient()
+	f.UseFirst, f.Strategy, f.Clock, f.User, f.OverlapCheck = c.UseFirst, c.Strategy, c.Clock, c.User, c.OverlapCheck
+	if c.roots == nil {
+		c.roots = map[string]*FakeClient{}
+	}
+	c.roots[root] = f
+	return f
+}
@@ -0,0 +1,129 @@
+package haci
+
+import (
+	"context"
+	"net/http"
+	neturl "net/url"
+	"sync"
+)
+
+// WithSession logs in to HaCi once with username and password and
+// authenticates further requests with the session cookie, instead of
+// sending basic auth with every request. When the session expires, the
+// client logs in again and repeats the request.
+func WithSession(username, password string) Option {
+	return func(c *clientConfig) error {
+		c.username, c.password = username, password
+		c.basicAuth, c.session, c.tokens = false, true, nil
+		return nil
+	}
+}
+
+// session tracks the login of a client. It is shared with clients
+// created by WithRoot, which use the same cookie jar.
+type session struct {
+	mu sync.Mutex
+	// generation counts logins, so concurrent requests finding the
+	// session expired log in only once.
+	generation int
+	loggedIn   bool
+}
+
+// authenticated authorizes requests. Within a session it logs in first if
+// necessary, and again if the server rejects the session.
+func (c *WebClient) authenticated(next Doer) Doer {
+	return DoerFunc(func(ctx context.Context, req *Request) (*Response, error) {
+		if c.session == nil {
+			if err := c.authorize(ctx, req, false); err != nil {
+				return nil, err
+			}
+			resp, err := next.Do(ctx, req)
+			if err != nil || resp.Status() != http.StatusUnauthorized || c.credentials == nil {
+				return resp, err
+			}
+			// The credentials may have been rotated.
+			if err := c.authorize(ctx, req, true); err != nil {
+				return nil, err
+			}
+			return next.Do(ctx, req)
+		}
+
+		generation, err := c.login(ctx, -1)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := next.Do(ctx, req)
+		if err != nil || resp.Status() != http.StatusUnauthorized {
+			return resp, err
+		}
+
+		c.log.Info("HaCi session expired, logging in again", "root", c.Root)
+		if _, err := c.login(ctx, generation); err != nil {
+			return nil, err
+		}
+		return next.Do(ctx, req)
+	})
+}
+
+// login logs in unless there is a session already that is newer than the
+// one of generation, -1 meaning any session. It returns the generation of
+// the current session.
+func (c *WebClient) login(ctx context.Context, generation int) (int, error) {
+	c.session.mu.Lock()
+	defer c.session.mu.Unlock()
+
+	if c.session.loggedIn && c.session.generation != generation {
+		return c.session.generation, nil
+	}
+
+	username, password, err := c.userPassword(ctx, true)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := c.transport().Do(ctx, &Request{
+		Method:   "POST",
+		Endpoint: "login",
+		Params: neturl.Values{
+			"username": {username},
+			"password": {password},
+		},
+		Header: http.Header{},
+	})
+	if err != nil {
+		return 0, err
+	}
+	if resp.Status() != 200 {
+		return 0, c.responseError("login", resp)
+	}
+
+	c.session.loggedIn = true
+	c.session.generation++
+	return c.session.generation, nil
+}
+
+// Logout ends the session of a client created with WithSession. The next
+// request logs in again.
+func (c *WebClient) Logout(ctx context.Context) (err error) {
+	ctx, end := c.operation(ctx, "Logout")
+	defer end(&err)
+
+	if c.session == nil {
+		return nil
+	}
+
+	c.session.mu.Lock()
+	defer c.session.mu.Unlock()
+	if !c.session.loggedIn {
+		return nil
+	}
+
+	resp, err := c.transport().Do(ctx, &Request{Method: "POST", Endpoint: "logout", Header: http.Header{}})
+	if err != nil {
+		return err
+	}
+	if resp.Status() != 200 {
+		return c.responseError("logout", resp)
+	}
+	c.session.loggedIn = false
+	return nil
+}
@@ -0,0 +1,97 @@
+package haci
+
+import "net"
+
+// FakeState is the content of a FakeClient. It can be stored as JSON, so
+// fixtures can be kept as test data and loaded with Restore.
+type FakeState struct {
+	Supernets map[string]FakeSupernetState `json:"supernets,omitempty"`
+	Added     []Network                    `json:"added,omitempty"`
+	Roots     []Root                       `json:"roots,omitempty"`
+}
+
+// FakeSupernetState is a supernet in a FakeState.
+type FakeSupernetState struct {
+	Networks []Network `json:"networks"`
+}
+
+// Snapshot returns the networks and roots of the fake. The strategy and
+// other settings, and the networks of other roots returned by WithRoot,
+// are not included.
+func (c *FakeClient) Snapshot() FakeState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	state := FakeState{Supernets: map[string]FakeSupernetState{}}
+	for supernet, s := range c.Supernets {
+		networks := Networks{}
+		for _, n := range s.Networks {
+			networks = append(networks, n)
+		}
+		state.Supernets[supernet] = FakeSupernetState{Networks: networks.SortByCIDR()}
+	}
+
+	added := Networks{}
+	for _, n := range c.Added {
+		added = append(added, n)
+	}
+	state.Added = added.SortByCIDR()
+
+	state.Roots = c.listRoots()
+	return state
+}
+
+// Restore replaces the networks and roots of the fake with state, as
+// returned by Snapshot, and forgets the networks of other roots. If state
+// is invalid, the fake is left unchanged.
+func (c *FakeClient) Restore(state FakeState) error {
+	// New networks get IDs above the restored ones.
+	var lastID int64
+	restoreID := func(n Network) {
+		if id, err := n.ID.Int64(); err == nil && id > lastID {
+			lastID = id
+		}
+	}
+
+	supernets := map[string]*FakeSupernet{}
+	for supernet, s := range state.Supernets {
+		_, n, err := net.ParseCIDR(supernet)
+		if err != nil {
+			return newError(ErrInvalidNetwork, "invalid supernet %q", supernet)
+		}
+		restored := &FakeSupernet{Network: *n, Networks: map[string]Network{}}
+		for _, n := range s.Networks {
+			if err := validateNetwork(n.Network); err != nil {
+				return err
+			}
+			restored.Networks[n.Network] = n
+			restoreID(n)
+		}
+		supernets[supernet] = restored
+	}
+
+	added := map[string]Network{}
+	for _, n := range state.Added {
+		if err := validateNetwork(n.Network); err != nil {
+			return err
+		}
+		added[n.Network] = n
+		restoreID(n)
+	}
+
+	var roots map[string]Root
+	if len(state.Roots) > 0 {
+		roots = map[string]Root{}
+		for _, r := range state.Roots {
+			roots[r.Name] = r
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.Supernets, c.Added, c.Roots = supernets, added, roots
+	c.roots = nil
+	c.lastID = lastID
+	return nil
+}
@@ -0,0 +1,113 @@
+package haci
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+
+	ccidr "github.com/apparentlymart/go-cidr/cidr"
+)
+
+// Strategy decides where in a supernet new subnets are placed.
+type Strategy int
+
+const (
+	// FirstFit takes the free subnet with the lowest address. This is what
+	// HaCi does itself.
+	FirstFit Strategy = iota
+
+	// LastFit takes the free subnet with the highest address.
+	LastFit
+
+	// Random takes any free subnet, with equal probability.
+	Random
+)
+
+// WithStrategy selects how Assign places subnets. Strategies other than
+// FirstFit are implemented by the client: it lists the free space in the
+// supernet and adds the chosen subnet.
+func WithStrategy(strategy Strategy) Option {
+	return func(c *clientConfig) error {
+		if strategy < FirstFit || strategy > Random {
+			return fmt.Errorf("unknown strategy %d", strategy)
+		}
+		c.strategy = strategy
+		return nil
+	}
+}
+
+// pick chooses a subnet with prefix length cidr from free, a list of free
+// blocks in address order as returned by FreeSubnets.
+func (s Strategy) pick(free []string, cidr int) (string, error) {
+	if len(free) == 0 {
+		return "", ErrNoFreeSubnet
+	}
+
+	blocks := []*net.IPNet{}
+	for _, f := range free {
+		_, block, err := net.ParseCIDR(f)
+		if err != nil {
+			return "", err
+		}
+		blocks = append(blocks, block)
+	}
+
+	// subnets returns the number of subnets of size cidr in a block.
+	subnets := func(block *net.IPNet) *big.Int {
+		ones, _ := block.Mask.Size()
+		return new(big.Int).Lsh(big.NewInt(1), uint(cidr-ones))
+	}
+
+	block, index := blocks[0], big.NewInt(0)
+	switch s {
+	case LastFit:
+		block = blocks[len(blocks)-1]
+		index.Sub(subnets(block), big.NewInt(1))
+	case Random:
+		total := big.NewInt(0)
+		for _, b := range blocks {
+			total.Add(total, subnets(b))
+		}
+		r, err := rand.Int(rand.Reader, total)
+		if err != nil {
+			return "", err
+		}
+		for _, b := range blocks {
+			if r.Cmp(subnets(b)) < 0 {
+				block, index = b, r
+				break
+			}
+			r.Sub(r, subnets(b))
+		}
+	}
+
+	ones, _ := block.Mask.Size()
+	subnet, err := ccidr.SubnetBig(block, cidr-ones, index)
+	if err != nil {
+		return "", err
+	}
+	return subnet.String(), nil
+}
+
+// assignWithStrategy adds a free subnet chosen by the strategy. If another
+// client takes it first, a new one is chosen.
+func (c *WebClient) assignWithStrategy(ctx context.Context, supernet, description string, cidr int, tags []string) (Network, error) {
+	for attempt := 0; ; attempt++ {
+		subnet, err := c.PeekFree(ctx, supernet, cidr)
+		if err != nil {
+			return Network{}, err
+		}
+
+		err = c.Add(ctx, subnet, description, tags)
+		if errors.Is(err, ErrAlreadyExists) && attempt < 3 {
+			continue
+		}
+		if err != nil {
+			return Network{}, err
+		}
+		return Network{Network: subnet, Description: description, Tags: tags}, nil
+	}
+}
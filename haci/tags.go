@@ -0,0 +1,283 @@
+package haci
+
+import (
+	"context"
+	neturl "net/url"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// AddTags adds tags to a network, keeping its existing tags and description.
+// Tags the network already has are not duplicated.
+func (c *WebClient) AddTags(ctx context.Context, network string, tags []string) (err error) {
+	ctx, end := c.operation(ctx, "AddTags", "network", network)
+	defer end(&err)
+
+	return c.modifyTags(ctx, network, func(existing []string) []string {
+		return mergeTags(existing, tags)
+	})
+}
+
+// RemoveTags removes tags from a network, keeping its other tags and description.
+func (c *WebClient) RemoveTags(ctx context.Context, network string, tags []string) (err error) {
+	ctx, end := c.operation(ctx, "RemoveTags", "network", network)
+	defer end(&err)
+
+	return c.modifyTags(ctx, network, func(existing []string) []string {
+		return removeTags(existing, tags)
+	})
+}
+
+// modifyTags reads a network, changes its tags and writes it back. Tag
+// changes made through this client are serialized so concurrent calls
+// don't overwrite each other.
+func (c *WebClient) modifyTags(ctx context.Context, network string, modify func([]string) []string) error {
+	c.tagMu.Lock()
+	defer c.tagMu.Unlock()
+
+	n, err := c.Get(ctx, network)
+	if err != nil {
+		return err
+	}
+
+	tags := modify(n.Tags)
+	if sameTags(tags, n.Tags) {
+		return nil
+	}
+	return c.Update(ctx, network, n.Description, tags)
+}
+
+// SearchTags returns the networks carrying all of tags if matchAll is true,
+// or any of them otherwise. The tag search of HaCi is used to narrow down
+// the result, the tags are then matched by the client.
+func (c *WebClient) SearchTags(ctx context.Context, tags []string, matchAll bool) (networks []Network, err error) {
+	ctx, end := c.operation(ctx, "SearchTags")
+	defer end(&err)
+
+	if err := c.require(ctx, CapTagSearch); err != nil {
+		return []Network{}, err
+	}
+
+	tagValues, err := c.tagValues(tags)
+	if err != nil {
+		return []Network{}, err
+	}
+
+	values := neturl.Values{
+		"rootName":    {c.Root},
+		"search":      {""},
+		"tags":        tagValues,
+		"withDetails": {"1"},
+	}
+	if matchAll {
+		values["tagsAll"] = []string{"1"}
+	}
+	resp, err := c.get(ctx, "search", &values, &networks)
+
+	if err != nil {
+		return []Network{}, err
+	}
+
+	if resp.Status() != 200 {
+		return []Network{}, c.responseError("search", resp)
+	}
+
+	return filterTags(networks, tags, matchAll), nil
+}
+
+// SearchTags matches tags like WebClient, including its validation of
+// the tags.
+func (c *FakeClient) SearchTags(ctx context.Context, tags []string, matchAll bool) ([]Network, error) {
+	if err := c.inject(ctx, "SearchTags", tags, matchAll); err != nil {
+		return nil, err
+	}
+	if err := validateTags(tags, false); err != nil {
+		return []Network{}, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return filterTags(c.networks(), tags, matchAll), nil
+}
+
+// tagValues validates tags and encodes them as values of the tags
+// parameter: one value per tag, or a single space-separated value with
+// WithJoinedTags. No tags are sent as an empty value, which clears the
+// tags of a network on update.
+func (c *WebClient) tagValues(tags []string) ([]string, error) {
+	if err := validateTags(tags, c.joinedTags); err != nil {
+		return nil, err
+	}
+	if c.joinedTags || len(tags) == 0 {
+		return []string{strings.Join(tags, " ")}, nil
+	}
+	return tags, nil
+}
+
+// validateTags rejects tags that would not come back unchanged from
+// HaCi: empty tags, tags with surrounding whitespace or control
+// characters, and tags containing whitespace if they are joined.
+func validateTags(tags []string, joined bool) error {
+	for _, t := range tags {
+		switch {
+		case t == "":
+			return newError(ErrInvalidTag, "empty tag")
+		case strings.TrimSpace(t) != t:
+			return newError(ErrInvalidTag, "tag %q has leading or trailing whitespace", t)
+		case strings.IndexFunc(t, unicode.IsControl) >= 0:
+			return newError(ErrInvalidTag, "tag %q contains control characters", t)
+		case joined && strings.IndexFunc(t, unicode.IsSpace) >= 0:
+			return newError(ErrInvalidTag, "tag %q contains whitespace", t)
+		}
+	}
+	return nil
+}
+
+// filterTags returns the networks that carry all or any of tags.
+func filterTags(networks []Network, tags []string, matchAll bool) []Network {
+	matching := []Network{}
+	for _, n := range networks {
+		if matchTags(n.Tags, tags, matchAll) {
+			matching = append(matching, n)
+		}
+	}
+	return matching
+}
+
+func matchTags(have, want []string, matchAll bool) bool {
+	for _, t := range want {
+		found := hasString(have, t)
+		if found && !matchAll {
+			return true
+		}
+		if !found && matchAll {
+			return false
+		}
+	}
+	return matchAll
+}
+
+func (c *FakeClient) AddTags(ctx context.Context, network string, tags []string) error {
+	if err := c.inject(ctx, "AddTags", network, tags); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n, err := c.get(network)
+	if err != nil {
+		return err
+	}
+	return c.update(network, n.Description, mergeTags(n.Tags, tags))
+}
+
+func (c *FakeClient) RemoveTags(ctx context.Context, network string, tags []string) error {
+	if err := c.inject(ctx, "RemoveTags", network, tags); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n, err := c.get(network)
+	if err != nil {
+		return err
+	}
+	return c.update(network, n.Description, removeTags(n.Tags, tags))
+}
+
+// mergeTags returns existing followed by the tags from add it doesn't contain.
+func mergeTags(existing, add []string) []string {
+	merged := append([]string{}, existing...)
+	for _, t := range add {
+		if !hasString(merged, t) {
+			merged = append(merged, t)
+		}
+	}
+	return merged
+}
+
+// removeTags returns existing without the tags in remove.
+func removeTags(existing, remove []string) []string {
+	kept := []string{}
+	for _, t := range existing {
+		if !hasString(remove, t) {
+			kept = append(kept, t)
+		}
+	}
+	return kept
+}
+
+// sameTags reports whether a and b contain the same tags, ignoring order.
+func sameTags(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, t := range a {
+		if !hasString(b, t) {
+			return false
+		}
+	}
+	for _, t := range b {
+		if !hasString(a, t) {
+			return false
+		}
+	}
+	return true
+}
+
+// TagMap returns the tags of the network as key=value pairs. A tag without
+// "=" is returned as a key with an empty value. If a key appears more than
+// once, the last value wins.
+func (n Network) TagMap() map[string]string {
+	m := map[string]string{}
+	for _, t := range n.Tags {
+		k, v := splitTag(t)
+		m[k] = v
+	}
+	return m
+}
+
+// HasTag reports whether the network has a tag with the given key, either
+// as a plain tag or as a key=value pair.
+func (n Network) HasTag(key string) bool {
+	_, ok := n.TagValue(key)
+	return ok
+}
+
+// TagValue returns the value of the key=value tag with the given key.
+func (n Network) TagValue(key string) (value string, ok bool) {
+	for _, t := range n.Tags {
+		if k, v := splitTag(t); k == key {
+			value, ok = v, true
+		}
+	}
+	return
+}
+
+// KeyValueTags converts m to key=value tags, sorted by key, followed by
+// the plain tags. The result can be passed as tags to Assign, Add and
+// the other calls that take tags.
+func KeyValueTags(m map[string]string, plain ...string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	tags := make([]string, 0, len(m)+len(plain))
+	for _, k := range keys {
+		tags = append(tags, k+"="+m[k])
+	}
+	return append(tags, plain...)
+}
+
+func splitTag(tag string) (key, value string) {
+	if i := strings.Index(tag, "="); i >= 0 {
+		return tag[:i], tag[i+1:]
+	}
+	return tag, ""
+}
@@ -0,0 +1,105 @@
+package haci
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// DescriptionTemplate renders network descriptions from structured fields,
+// e.g. "{{.Project}}-{{.Env}}-{{.Seq}}", so all clients follow the same
+// naming convention.
+type DescriptionTemplate struct {
+	tmpl *template.Template
+
+	// mu serializes the numbering of Assign.
+	mu sync.Mutex
+}
+
+// NewDescriptionTemplate parses a text/template for descriptions. Fields
+// used by the template must be present when rendering it.
+func NewDescriptionTemplate(text string) (*DescriptionTemplate, error) {
+	tmpl, err := template.New("description").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return &DescriptionTemplate{tmpl: tmpl}, nil
+}
+
+// Render returns the description for fields.
+func (t *DescriptionTemplate) Render(fields map[string]string) (string, error) {
+	var b strings.Builder
+	if err := t.tmpl.Execute(&b, fields); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// Assign assigns a free subnet of size cidr in supernet, with the rendered
+// description and the fields as key=value tags. Unless fields contains it,
+// the field Seq is set to one more than the highest Seq of the networks in
+// supernet whose descriptions match the template with the other fields,
+// or to 1 if there are none. Concurrent calls of the same template are
+// serialized, so they get distinct numbers.
+func (t *DescriptionTemplate) Assign(ctx context.Context, c Client, supernet string, cidr int, fields map[string]string) (Network, error) {
+	all := map[string]string{}
+	for k, v := range fields {
+		all[k] = v
+	}
+	if _, ok := all["Seq"]; !ok {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+
+		seq, err := t.nextSeq(ctx, c, supernet, all)
+		if err != nil {
+			return Network{}, err
+		}
+		all["Seq"] = strconv.Itoa(seq)
+	}
+
+	description, err := t.Render(all)
+	if err != nil {
+		return Network{}, err
+	}
+	return c.Assign(ctx, supernet, description, cidr, KeyValueTags(all))
+}
+
+// seqMarker stands in for Seq when rendering the template to find the
+// numbers in existing descriptions.
+const seqMarker = "\x00seq\x00"
+
+// nextSeq returns one more than the highest Seq in the descriptions of the
+// networks in supernet rendered from fields.
+func (t *DescriptionTemplate) nextSeq(ctx context.Context, c Client, supernet string, fields map[string]string) (int, error) {
+	marked := map[string]string{"Seq": seqMarker}
+	for k, v := range fields {
+		marked[k] = v
+	}
+	rendered, err := t.Render(marked)
+	if err != nil {
+		return 0, err
+	}
+	prefix, suffix, found := strings.Cut(rendered, seqMarker)
+	if !found {
+		// The descriptions don't contain the number.
+		return 1, nil
+	}
+
+	networks, err := c.List(ctx, supernet)
+	if err != nil {
+		return 0, err
+	}
+	highest := 0
+	for _, n := range networks {
+		d := n.Description
+		if len(d) < len(prefix)+len(suffix) || !strings.HasPrefix(d, prefix) || !strings.HasSuffix(d, suffix) {
+			continue
+		}
+		if seq, err := strconv.Atoi(d[len(prefix) : len(d)-len(suffix)]); err == nil && seq > highest {
+			highest = seq
+		}
+	}
+	return highest + 1, nil
+}
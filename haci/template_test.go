@@ -0,0 +1,71 @@
+package haci_test
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/Nexinto/go-haci-client/haci"
+)
+
+func TestDescriptionTemplateSeq(t *testing.T) {
+	ctx := context.Background()
+	c := haci.NewFakeClient()
+	for _, n := range []string{"10.0.0.0/16", "10.0.200.0/24"} {
+		if err := c.Add(ctx, n, "other-7", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tmpl, err := haci.NewDescriptionTemplate("{{.Project}}-{{.Seq}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assign := func() haci.Network {
+		n, err := tmpl.Assign(ctx, c, "10.0.0.0/16", 24, map[string]string{"Project": "p"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	first, second := assign(), assign()
+	if first.Description != "p-1" || second.Description != "p-2" {
+		t.Errorf("assigned %q and %q, want p-1 and p-2", first.Description, second.Description)
+	}
+	if err := c.Delete(ctx, first.Network); err != nil {
+		t.Fatal(err)
+	}
+	if n := assign(); n.Description != "p-3" {
+		t.Errorf("assigned %q after a delete, want p-3", n.Description)
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	descriptions := []string{}
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n, err := tmpl.Assign(ctx, c, "10.0.0.0/16", 24, map[string]string{"Project": "p"})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			descriptions = append(descriptions, n.Description)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	sort.Strings(descriptions)
+	if len(descriptions) != 5 {
+		t.Fatalf("%d concurrent assignments succeeded", len(descriptions))
+	}
+	for i, want := range []string{"p-4", "p-5", "p-6", "p-7", "p-8"} {
+		if descriptions[i] != want {
+			t.Errorf("concurrent assignments got %q, want p-4 to p-8", descriptions)
+			break
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package haci
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+)
+
+// TLSOptions configures how a WebClient verifies the HaCi server.
+// The zero value verifies the server certificate against the system roots.
+type TLSOptions struct {
+	// InsecureSkipVerify disables certificate verification. Only use this
+	// for lab setups with self-signed certificates.
+	InsecureSkipVerify bool
+
+	// RootCAs replaces the system roots used to verify the server.
+	RootCAs *x509.CertPool
+
+	// CAFile is the path of a PEM bundle with additional CA certificates.
+	// They are added to RootCAs, or to the system roots if RootCAs is nil.
+	CAFile string
+
+	// Certificates are presented to servers requiring client certificates.
+	Certificates []tls.Certificate
+
+	// CertFile and KeyFile are the paths of a PEM encoded client
+	// certificate and its key, added to Certificates.
+	CertFile string
+	KeyFile  string
+}
+
+func (o TLSOptions) config() (*tls.Config, error) {
+	config := &tls.Config{
+		InsecureSkipVerify: o.InsecureSkipVerify,
+		RootCAs:            o.RootCAs,
+		Certificates:       o.Certificates,
+	}
+
+	if o.CertFile != "" || o.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading client certificate: %s", err.Error())
+		}
+		config.Certificates = append(append([]tls.Certificate{}, o.Certificates...), cert)
+	}
+
+	if o.CAFile != "" {
+		pem, err := ioutil.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading CA file: %s", err.Error())
+		}
+		if config.RootCAs == nil {
+			if config.RootCAs, err = x509.SystemCertPool(); err != nil {
+				config.RootCAs = x509.NewCertPool()
+			}
+		} else {
+			config.RootCAs = config.RootCAs.Clone()
+		}
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", o.CAFile)
+		}
+	}
+
+	return config, nil
+}
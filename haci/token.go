@@ -0,0 +1,36 @@
+package haci
+
+import "context"
+
+// TokenSource supplies bearer tokens. Token is called for every request,
+// so implementations should cache the token and refresh it shortly before
+// it expires.
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// TokenSourceFunc adapts a function to a TokenSource.
+type TokenSourceFunc func(ctx context.Context) (string, error)
+
+func (f TokenSourceFunc) Token(ctx context.Context) (string, error) { return f(ctx) }
+
+type staticToken string
+
+func (t staticToken) Token(ctx context.Context) (string, error) { return string(t), nil }
+
+// WithBearerToken authenticates every request with an Authorization:
+// Bearer header, e.g. for HaCi behind an SSO gateway. It replaces
+// WithBasicAuth and WithSession.
+func WithBearerToken(token string) Option {
+	return WithTokenSource(staticToken(token))
+}
+
+// WithTokenSource is WithBearerToken with tokens taken from ts, for tokens
+// that have to be refreshed.
+func WithTokenSource(ts TokenSource) Option {
+	return func(c *clientConfig) error {
+		c.tokens = ts
+		c.basicAuth, c.session = false, false
+		return nil
+	}
+}
@@ -0,0 +1,82 @@
+package haci
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// WithTracerProvider creates a span for every Client operation, as a child
+// of the span in the context passed to it, with a child span for each HaCi
+// request the operation makes. Traced reads are not coalesced with other
+// identical reads, so that each request is part of the right trace.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(c *clientConfig) error {
+		c.tracer = tp.Tracer("github.com/Nexinto/go-haci-client/haci")
+		return nil
+	}
+}
+
+// operation starts the span of a Client operation, which is the parent
+// of the spans of its requests. params are pairs of attribute names and
+// values, e.g. "network", network. The function returned records the
+// error err points to and ends the span; defer it with the named error
+// result of the operation.
+func (c *WebClient) operation(ctx context.Context, name string, params ...string) (context.Context, func(err *error)) {
+	if c.tracer == nil {
+		return ctx, func(*error) {}
+	}
+
+	attrs := []attribute.KeyValue{attribute.String("haci.root", c.Root)}
+	for i := 0; i+1 < len(params); i += 2 {
+		attrs = append(attrs, attribute.String("haci."+params[i], params[i+1]))
+	}
+	ctx, span := c.tracer.Start(ctx, "haci."+name, trace.WithAttributes(attrs...))
+
+	return ctx, func(err *error) {
+		if *err != nil {
+			span.RecordError(*err)
+			span.SetStatus(codes.Error, (*err).Error())
+		}
+		span.End()
+	}
+}
+
+// startSpan starts a span for a request to endpoint. It returns ctx
+// unchanged and a nil span if tracing is disabled.
+func (c *WebClient) startSpan(ctx context.Context, endpoint string, params map[string][]string) (context.Context, trace.Span) {
+	if c.tracer == nil {
+		return ctx, nil
+	}
+
+	attrs := []attribute.KeyValue{attribute.String("haci.root", c.Root)}
+	for _, p := range []string{"network", "supernet"} {
+		if v, ok := params[p]; ok && len(v) > 0 {
+			attrs = append(attrs, attribute.String("haci."+p, v[0]))
+		}
+	}
+
+	return c.tracer.Start(ctx, "haci."+endpoint,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...))
+}
+
+// endSpan records the outcome of a request and ends the span.
+func endSpan(span trace.Span, status int, err error) {
+	if span == nil {
+		return
+	}
+	if status != 0 {
+		span.SetAttributes(attribute.Int("http.status_code", status))
+	}
+	switch {
+	case err != nil:
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	case status != 200:
+		span.SetStatus(codes.Error, "unexpected status")
+	}
+	span.End()
+}
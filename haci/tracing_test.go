@@ -0,0 +1,128 @@
+package haci_test
+
+import (
+	"context"
+	"encoding/binary"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/embedded"
+
+	"github.com/Nexinto/go-haci-client/haci"
+	"github.com/Nexinto/go-haci-client/haci/hacitest"
+)
+
+// recorder is a Tracer that records the names of spans and of their
+// parents.
+type recorder struct {
+	embedded.Tracer
+
+	mu      sync.Mutex
+	last    uint64
+	names   map[trace.SpanID]string
+	parents map[string][]string
+}
+
+type recorderProvider struct {
+	embedded.TracerProvider
+	r *recorder
+}
+
+func (p recorderProvider) Tracer(string, ...trace.TracerOption) trace.Tracer { return p.r }
+
+func (r *recorder) Start(ctx context.Context, name string, _ ...trace.SpanStartOption) (context.Context, trace.Span) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.last++
+	var id trace.SpanID
+	binary.BigEndian.PutUint64(id[:], r.last)
+	sc := trace.NewSpanContext(trace.SpanContextConfig{TraceID: trace.TraceID{1}, SpanID: id})
+
+	parent := ""
+	if p := trace.SpanContextFromContext(ctx); p.IsValid() {
+		parent = r.names[p.SpanID()]
+	}
+	r.names[id] = name
+	r.parents[name] = append(r.parents[name], parent)
+
+	ctx = trace.ContextWithSpanContext(ctx, sc)
+	return ctx, trace.SpanFromContext(ctx)
+}
+
+// reset returns the parents recorded for each span name and forgets them.
+func (r *recorder) reset() map[string][]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	parents := r.parents
+	r.names, r.parents = map[trace.SpanID]string{}, map[string][]string{}
+	return parents
+}
+
+func TestTracingOperations(t *testing.T) {
+	ctx := context.Background()
+	s := hacitest.NewServer("test")
+	defer s.Close()
+	if err := s.Fake("test").Add(ctx, "10.0.0.0/16", "supernet", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	r := &recorder{}
+	r.reset()
+	c, err := haci.NewWebClient(s.URL, haci.WithRoot("test"), haci.WithTracerProvider(recorderProvider{r: r}), haci.WithAllowReset("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.CreateRoot(ctx, "other", "", false); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		op   string
+		call func() error
+		want map[string][]string
+	}{
+		{"Get", func() error { _, err := c.Get(ctx, "10.0.0.0/16"); return err }, map[string][]string{
+			"haci.Get":               {""},
+			"haci.getNetworkDetails": {"haci.Get"},
+		}},
+		{"AssignMany", func() error {
+			_, err := c.AssignMany(ctx, "10.0.0.0/16", "many", 24, 2, nil)
+			return err
+		}, map[string][]string{
+			"haci.AssignMany":       {""},
+			"haci.Assign":           {"haci.AssignMany", "haci.AssignMany"},
+			"haci.assignFreeSubnet": {"haci.Assign", "haci.Assign"},
+		}},
+		{"GetByIP", func() error { _, err := c.GetByIP(ctx, "10.0.1.1"); return err }, nil},
+		{"Move", func() error { return c.Move(ctx, "10.0.0.0/24", "other", false) }, nil},
+		{"Reset", func() error { return c.Reset(ctx) }, nil},
+	} {
+		r.reset()
+		if err := test.call(); err != nil {
+			t.Errorf("%s: %s", test.op, err)
+		}
+		parents := r.reset()
+
+		// Every span is part of the one trace of the operation.
+		roots := 0
+		for name, ps := range parents {
+			for _, p := range ps {
+				if p == "" {
+					roots++
+					if name != "haci."+test.op {
+						t.Errorf("%s: span %s has no parent", test.op, name)
+					}
+				}
+			}
+		}
+		if roots != 1 {
+			t.Errorf("%s: %d spans without parent, want 1: %v", test.op, roots, parents)
+		}
+		for name, want := range test.want {
+			if got := parents[name]; len(got) != len(want) || len(got) > 0 && got[0] != want[0] {
+				t.Errorf("%s: parents of %s are %q, want %q", test.op, name, got, want)
+			}
+		}
+	}
+}
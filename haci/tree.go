@@ -0,0 +1,248 @@
+package haci
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// ListRecursive returns all networks below supernet, not just its direct
+// children. The subnets of each level of the hierarchy are fetched
+// concurrently, limited by WithConcurrency.
+func (c *WebClient) ListRecursive(ctx context.Context, supernet string) (networks []Network, err error) {
+	ctx, end := c.operation(ctx, "ListRecursive", "supernet", supernet)
+	defer end(&err)
+
+	return listRecursive(ctx, c.List, supernet, c.concurrency)
+}
+
+func (c *FakeClient) ListRecursive(ctx context.Context, supernet string) ([]Network, error) {
+	return listRecursive(ctx, c.List, supernet, 1)
+}
+
+// listRecursive walks the hierarchy below supernet level by level, listing
+// up to parallel networks at the same time. Networks are returned in
+// breadth-first order.
+func listRecursive(ctx context.Context, list func(context.Context, string) ([]Network, error), supernet string, parallel int) ([]Network, error) {
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	all := []Network{}
+	seen := map[string]bool{supernet: true}
+	level := []string{supernet}
+
+	for len(level) > 0 {
+		children := make([][]Network, len(level))
+
+		g, gctx := errgroup.WithContext(ctx)
+		g.SetLimit(parallel)
+		for i, network := range level {
+			i, network := i, network
+			g.Go(func() error {
+				networks, err := list(gctx, network)
+				children[i] = networks
+				return err
+			})
+		}
+		if err := g.Wait(); err != nil {
+			return nil, err
+		}
+
+		level = nil
+		for _, networks := range children {
+			for _, n := range networks {
+				if seen[n.Network] {
+					continue
+				}
+				seen[n.Network] = true
+				all = append(all, n)
+				level = append(level, n.Network)
+			}
+		}
+	}
+
+	return all, nil
+}
+
+// TreeNode is a network together with its subnets.
+type TreeNode struct {
+	Network
+	Children []*TreeNode
+}
+
+// Tree returns the hierarchy of networks below supernet. Children are
+// sorted by address.
+func (c *WebClient) Tree(ctx context.Context, supernet string) (root *TreeNode, err error) {
+	ctx, end := c.operation(ctx, "Tree", "supernet", supernet)
+	defer end(&err)
+
+	return tree(ctx, c, supernet)
+}
+
+func (c *FakeClient) Tree(ctx context.Context, supernet string) (*TreeNode, error) {
+	return tree(ctx, c, supernet)
+}
+
+func tree(ctx context.Context, c Client, supernet string) (*TreeNode, error) {
+	root, err := c.Get(ctx, supernet)
+	if errors.Is(err, ErrNotFound) {
+		// Supernets don't have to be networks themselves.
+		root, err = Network{Network: supernet}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	networks, err := c.ListRecursive(ctx, supernet)
+	if err != nil {
+		return nil, err
+	}
+
+	return buildTree(root, networks), nil
+}
+
+// buildTree arranges networks below root by address containment.
+func buildTree(root Network, networks []Network) *TreeNode {
+	sorted := append([]Network{}, networks...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return prefixLen(sorted[i].Network) < prefixLen(sorted[j].Network)
+	})
+
+	top := &TreeNode{Network: root}
+	for _, n := range sorted {
+		parent := top
+		for descend := true; descend; {
+			descend = false
+			for _, child := range parent.Children {
+				if containsCIDR(child.Network.Network, n.Network) {
+					parent, descend = child, true
+					break
+				}
+			}
+		}
+		parent.Children = append(parent.Children, &TreeNode{Network: n})
+	}
+
+	sortTree(top)
+	return top
+}
+
+func sortTree(node *TreeNode) {
+	sort.Slice(node.Children, func(i, j int) bool {
+		return compareCIDR(node.Children[i].Network.Network, node.Children[j].Network.Network) < 0
+	})
+	for _, child := range node.Children {
+		sortTree(child)
+	}
+}
+
+// containsCIDR reports whether the network outer contains the different
+// network inner.
+func containsCIDR(outer, inner string) bool {
+	_, o, err := net.ParseCIDR(outer)
+	if err != nil {
+		return false
+	}
+	ip, i, err := net.ParseCIDR(inner)
+	if err != nil {
+		return false
+	}
+	ol, _ := o.Mask.Size()
+	il, _ := i.Mask.Size()
+	return ol < il && o.Contains(ip)
+}
+
+// GetParent returns the most specific network containing network. It
+// returns ErrNotFound if network is at the top of the hierarchy. It reads
+// the networks of the root with a single search.
+func (c *WebClient) GetParent(ctx context.Context, network string) (network1 Network, err error) {
+	ctx, end := c.operation(ctx, "GetParent", "network", network)
+	defer end(&err)
+
+	return getParent(ctx, c, network)
+}
+
+// GetChildren returns the direct subnets of network.
+func (c *WebClient) GetChildren(ctx context.Context, network string) (networks []Network, err error) {
+	ctx, end := c.operation(ctx, "GetChildren", "network", network)
+	defer end(&err)
+
+	return c.List(ctx, network)
+}
+
+// GetByIP returns the most specific network containing the address ip,
+// reading the networks of the root with a single search.
+func (c *WebClient) GetByIP(ctx context.Context, ip string) (network1 Network, err error) {
+	ctx, end := c.operation(ctx, "GetByIP", "ip", ip)
+	defer end(&err)
+
+	return getByIP(ctx, c, ip)
+}
+
+func (c *FakeClient) GetParent(ctx context.Context, network string) (Network, error) {
+	return getParent(ctx, c, network)
+}
+
+func (c *FakeClient) GetChildren(ctx context.Context, network string) ([]Network, error) {
+	return c.List(ctx, network)
+}
+
+func (c *FakeClient) GetByIP(ctx context.Context, ip string) (Network, error) {
+	return getByIP(ctx, c, ip)
+}
+
+func getParent(ctx context.Context, c networkSource, network string) (Network, error) {
+	if _, _, err := net.ParseCIDR(network); err != nil {
+		return Network{}, err
+	}
+
+	parent, err := enclosing(ctx, c, func(n string) bool {
+		return containsCIDR(n, network)
+	})
+	if errors.Is(err, ErrNotFound) {
+		return Network{}, newError(ErrNotFound, "network %s has no parent", network)
+	}
+	return parent, err
+}
+
+func getByIP(ctx context.Context, c networkSource, address string) (Network, error) {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return Network{}, fmt.Errorf("invalid IP address %q", address)
+	}
+
+	n, err := enclosing(ctx, c, func(n string) bool {
+		_, network, err := net.ParseCIDR(n)
+		return err == nil && network.Contains(ip)
+	})
+	if errors.Is(err, ErrNotFound) {
+		return Network{}, newError(ErrNotFound, "no network contains %s", address)
+	}
+	return n, err
+}
+
+// enclosing returns the network with the longest prefix for which contains
+// is true. All networks of the root are read with a single search, one at
+// a time, instead of looking up every prefix length.
+func enclosing(ctx context.Context, c networkSource, contains func(network string) bool) (Network, error) {
+	var found Network
+	longest := -1
+	err := c.eachNetwork(ctx, func(n Network) error {
+		if l := prefixLen(n.Network); l > longest && contains(n.Network) {
+			found, longest = n, l
+		}
+		return nil
+	})
+	if err != nil {
+		return Network{}, err
+	}
+	if longest < 0 {
+		return Network{}, ErrNotFound
+	}
+	return found, nil
+}
@@ -0,0 +1,51 @@
+package haci_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Nexinto/go-haci-client/haci"
+	"github.com/Nexinto/go-haci-client/haci/hacitest"
+)
+
+func TestEnclosingSingleRequest(t *testing.T) {
+	ctx := context.Background()
+	s := hacitest.NewServer("test")
+	defer s.Close()
+	for _, n := range []string{"10.0.0.0/8", "10.1.0.0/16", "10.1.2.0/24", "10.1.2.3/32", "2001:db8::/32", "2001:db8:1::/48"} {
+		if err := s.Fake("test").Add(ctx, n, "", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c, err := haci.NewWebClient(s.URL, haci.WithRoot("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name string
+		call func() (haci.Network, error)
+		want string
+	}{
+		{"GetByIP host", func() (haci.Network, error) { return c.GetByIP(ctx, "10.1.2.3") }, "10.1.2.3/32"},
+		{"GetByIP", func() (haci.Network, error) { return c.GetByIP(ctx, "10.1.2.4") }, "10.1.2.0/24"},
+		{"GetByIP top", func() (haci.Network, error) { return c.GetByIP(ctx, "10.9.9.9") }, "10.0.0.0/8"},
+		{"GetByIP IPv6", func() (haci.Network, error) { return c.GetByIP(ctx, "2001:db8:1::1") }, "2001:db8:1::/48"},
+		{"GetByIP missing", func() (haci.Network, error) { return c.GetByIP(ctx, "192.168.0.1") }, ""},
+		{"GetParent", func() (haci.Network, error) { return c.GetParent(ctx, "10.1.2.0/24") }, "10.1.0.0/16"},
+		{"GetParent missing", func() (haci.Network, error) { return c.GetParent(ctx, "10.0.0.0/8") }, ""},
+	} {
+		before := len(s.Requests())
+		n, err := test.call()
+		switch {
+		case test.want == "" && !errors.Is(err, haci.ErrNotFound):
+			t.Errorf("%s: got %s, %v, want ErrNotFound", test.name, n.Network, err)
+		case test.want != "" && (err != nil || n.Network != test.want):
+			t.Errorf("%s: got %s, %v, want %s", test.name, n.Network, err, test.want)
+		}
+		if requests := len(s.Requests()) - before; requests != 1 {
+			t.Errorf("%s: sent %d requests, want 1", test.name, requests)
+		}
+	}
+}
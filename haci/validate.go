@@ -0,0 +1,33 @@
+package haci
+
+import "net"
+
+// validateNetwork checks that cidr is a network in CIDR notation without
+// host bits set, as HaCi stores it.
+func validateNetwork(cidr string) error {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return newError(ErrInvalidNetwork, "invalid network %q", cidr)
+	}
+	if network.String() != cidr {
+		return newError(ErrInvalidNetwork, "invalid network %q, did you mean %s", cidr, network)
+	}
+	return nil
+}
+
+// validateSubnet checks that supernet is a valid network and that a subnet
+// with prefix length cidr fits into it.
+func validateSubnet(supernet string, cidr int) error {
+	_, super, err := net.ParseCIDR(supernet)
+	if err != nil {
+		return newError(ErrInvalidNetwork, "invalid supernet %q", supernet)
+	}
+	ones, bits := super.Mask.Size()
+	if cidr < 0 || cidr > bits {
+		return newError(ErrInvalidPrefixLen, "prefix length %d is out of range 0-%d", cidr, bits)
+	}
+	if cidr < ones {
+		return newError(ErrInvalidPrefixLen, "prefix length %d does not fit into %s", cidr, supernet)
+	}
+	return nil
+}
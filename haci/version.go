@@ -0,0 +1,201 @@
+package haci
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	neturl "net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Capability is a RESTWrapper feature that only some HaCi versions have.
+type Capability string
+
+const (
+	// CapExactSearch is the exact parameter of search.
+	CapExactSearch Capability = "exactSearch"
+	// CapTagSearch is the tags parameter of search.
+	CapTagSearch Capability = "tagSearch"
+	// CapPOST means mutating endpoints accept POST requests.
+	CapPOST Capability = "post"
+)
+
+// Capabilities maps each capability to the first HaCi version having it.
+var Capabilities = map[Capability]string{
+	CapExactSearch: "0.97",
+	CapTagSearch:   "0.98",
+	CapPOST:        "0.98",
+}
+
+// serverInfo caches what is known about the server.
+type serverInfo struct {
+	mu sync.Mutex
+	// known is set once the version was requested successfully or found
+	// to be unknown; version is empty then if HaCi doesn't report it.
+	known   bool
+	version string
+}
+
+// lookup returns the cached version and whether there is one.
+func (s *serverInfo) lookup() (version string, known bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.version, s.known
+}
+
+func (s *serverInfo) store(version string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.known, s.version = true, version
+}
+
+// missingEndpoint lists the statuses with which HaCi versions without
+// getVersion answer it.
+var missingEndpoint = map[int]bool{
+	http.StatusBadRequest:       true,
+	http.StatusNotFound:         true,
+	http.StatusMethodNotAllowed: true,
+	http.StatusNotImplemented:   true,
+}
+
+// Version returns the version of the HaCi server. It is requested once
+// and then cached. If HaCi doesn't
+// report its version, that is cached too, and later calls fail with
+// ErrUnsupported without asking again.
+func (c *WebClient) Version(ctx context.Context) (version string, err error) {
+	ctx, end := c.operation(ctx, "Version")
+	defer end(&err)
+
+	if version, known := c.server.lookup(); known {
+		if version == "" {
+			return "", newError(ErrUnsupported, "HaCi does not report its version")
+		}
+		return version, nil
+	}
+
+	var body struct {
+		Version string `json:"version"`
+	}
+	resp, err := c.get(ctx, "getVersion", &neturl.Values{}, &body)
+
+	if err != nil {
+		return "", err
+	}
+
+	if resp.Status() != 200 {
+		if missingEndpoint[resp.Status()] {
+			c.server.store("")
+		}
+		return "", c.responseError("version", resp)
+	}
+
+	c.server.store(body.Version)
+	if body.Version == "" {
+		return "", newError(ErrUnsupported, "HaCi does not report its version")
+	}
+	return body.Version, nil
+}
+
+// Supports reports whether the HaCi server has capability. Servers that
+// don't report their version, usually because they lack the getVersion
+// endpoint, are assumed to be older than all versions in Capabilities and
+// to support none of them.
+func (c *WebClient) Supports(ctx context.Context, capability Capability) (bool, error) {
+	min, ok := Capabilities[capability]
+	if !ok {
+		return false, fmt.Errorf("unknown capability %q", capability)
+	}
+	version, err := c.Version(ctx)
+	if err != nil {
+		if _, known := c.server.lookup(); known {
+			return false, nil
+		}
+		return false, err
+	}
+	return compareVersions(version, min) >= 0, nil
+}
+
+// supports is Supports for the request methods. It only asks the server
+// with WithCapabilityDetection and otherwise assumes the capability.
+func (c *WebClient) supports(ctx context.Context, capability Capability) (bool, error) {
+	if !c.detectCaps {
+		return true, nil
+	}
+	return c.Supports(ctx, capability)
+}
+
+// require returns an error matching ErrUnsupported if the server lacks
+// capability.
+func (c *WebClient) require(ctx context.Context, capability Capability) error {
+	ok, err := c.supports(ctx, capability)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		version, _ := c.server.lookup()
+		if version == "" {
+			version = "of unknown version"
+		}
+		return newError(ErrUnsupported, "HaCi %s does not support %s", version, capability)
+	}
+	return nil
+}
+
+// Version returns "fake".
+func (c *FakeClient) Version(ctx context.Context) (string, error) {
+	if err := c.inject(ctx, "Version"); err != nil {
+		return "", err
+	}
+
+	return "fake", nil
+}
+
+// Supports returns true for all known capabilities.
+func (c *FakeClient) Supports(ctx context.Context, capability Capability) (bool, error) {
+	if err := c.inject(ctx, "Supports", capability); err != nil {
+		return false, err
+	}
+
+	if _, ok := Capabilities[capability]; !ok {
+		return false, fmt.Errorf("unknown capability %q", capability)
+	}
+	return true, nil
+}
+
+// compareVersions compares HaCi versions like "0.98c" part by part,
+// numerically and then by the letters following the number.
+func compareVersions(a, b string) int {
+	pa, pb := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var x, y string
+		if i < len(pa) {
+			x = pa[i]
+		}
+		if i < len(pb) {
+			y = pb[i]
+		}
+		nx, sx := splitVersionPart(x)
+		ny, sy := splitVersionPart(y)
+		if nx != ny {
+			if nx < ny {
+				return -1
+			}
+			return 1
+		}
+		if c := strings.Compare(sx, sy); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func splitVersionPart(part string) (int, string) {
+	i := 0
+	for i < len(part) && part[i] >= '0' && part[i] <= '9' {
+		i++
+	}
+	n, _ := strconv.Atoi(part[:i])
+	return n, part[i:]
+}
@@ -0,0 +1,71 @@
+package haci_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Nexinto/go-haci-client/haci"
+	"github.com/Nexinto/go-haci-client/haci/hacitest"
+)
+
+func TestUnknownVersion(t *testing.T) {
+	ctx := context.Background()
+	s := hacitest.NewServer("test")
+	defer s.Close()
+	s.Version = ""
+	c, err := haci.NewWebClient(s.URL, haci.WithRoot("test"), haci.WithCapabilityDetection())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for capability := range haci.Capabilities {
+		if ok, err := c.Supports(ctx, capability); ok || err != nil {
+			t.Errorf("Supports(%s) = %t, %v, want false", capability, ok, err)
+		}
+	}
+	for _, n := range []string{"10.0.0.0/24", "10.0.1.0/24"} {
+		if err := c.Add(ctx, n, "", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := c.SearchTags(ctx, []string{"x"}, false); !errors.Is(err, haci.ErrUnsupported) {
+		t.Errorf("SearchTags: got %v, want ErrUnsupported", err)
+	}
+	if _, err := c.Version(ctx); !errors.Is(err, haci.ErrUnsupported) {
+		t.Errorf("Version: got %v, want ErrUnsupported", err)
+	}
+
+	versions := 0
+	for _, r := range s.Requests() {
+		switch r.Endpoint {
+		case "getVersion":
+			versions++
+		case "addNet":
+			if r.Method != "GET" {
+				t.Errorf("addNet sent as %s to a server without POST", r.Method)
+			}
+		}
+	}
+	if versions != 1 {
+		t.Errorf("requested the version %d times, want once", versions)
+	}
+}
+
+func TestVersionRetriedAfterFailure(t *testing.T) {
+	ctx := context.Background()
+	s := hacitest.NewServer("test")
+	defer s.Close()
+	s.FailNext("getVersion", 1, 503)
+	c, err := haci.NewWebClient(s.URL, haci.WithRoot("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Supports(ctx, haci.CapPOST); err == nil {
+		t.Errorf("Supports succeeded although the server failed")
+	}
+	if ok, err := c.Supports(ctx, haci.CapPOST); !ok || err != nil {
+		t.Errorf("Supports = %t, %v, want true", ok, err)
+	}
+}